@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,13 +14,23 @@ import (
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 
+	"github.com/Conversly/db-ingestor/internal/api/ingestion"
 	"github.com/Conversly/db-ingestor/internal/config"
+	"github.com/Conversly/db-ingestor/internal/embedder"
 	"github.com/Conversly/db-ingestor/internal/loaders"
 	"github.com/Conversly/db-ingestor/internal/routes"
 	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
 )
 
 func main() {
+	role := flag.String("role", "", "Process role: api, worker or all (defaults to ROLE or all)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [--role api|worker|all]\n       %s migrate up | down [steps] | status\n       %s reembed start | resume | cutover | rollback | status\n", os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	err := godotenv.Load()
 	if err != nil {
 		fmt.Println("Warning: Error loading .env file", err)
@@ -31,12 +42,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrate(cfg, flag.Args()[1:]))
+	}
+	if flag.Arg(0) == "reembed" {
+		os.Exit(runReembed(cfg, flag.Args()[1:]))
+	}
+
+	if *role != "" {
+		cfg.Role = *role
+	}
+	if err := cfg.ValidateRole(); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.ValidateUploadSpool(); err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
 	cleanup := utils.InitLogger(cfg)
 	defer cleanup()
 
 	utils.Zlog.Info("Starting application",
 		zap.String("service", cfg.ServiceName),
 		zap.String("environment", cfg.Environment),
+		zap.String("role", cfg.Role),
 		zap.String("port", cfg.Port))
 
 	// Determine port
@@ -45,6 +76,13 @@ func main() {
 		port = "8070"
 	}
 
+	if cfg.AutoMigrate {
+		if err := migrateUp(cfg); err != nil {
+			utils.Zlog.Error("Failed to migrate database schema", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
 	// Initialize database client
 	db, err := loaders.NewPostgresClient(cfg.DatabaseURL, cfg.WorkerCount, cfg.BatchSize)
 	if err != nil {
@@ -57,14 +95,57 @@ func main() {
 		}
 	}()
 
+	// Refuse to start against a schema this binary was not built for
+	schemaCtx, schemaCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err = db.CheckSchema(schemaCtx)
+	schemaCancel()
+	if err != nil {
+		utils.Zlog.Error("Database schema check failed", zap.Error(err))
+		os.Exit(1)
+	}
+
+	langCtx, langCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err = db.SetSearchLanguage(langCtx, cfg.SearchLanguage)
+	langCancel()
+	if err != nil {
+		utils.Zlog.Error("Invalid SEARCH_LANGUAGE", zap.Error(err))
+		os.Exit(1)
+	}
+
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// API processes only enqueue; worker processes consume the shared queue
+	dispatcher := webhooks.NewDispatcher(db, cfg.WebhookSecret, cfg.WebhookMaxAttempts, cfg.QueuePollInterval)
+	textEmbedder := newEmbedder(cfg)
+	if textEmbedder != nil {
+		// Chunks of the global dimensions need their own partial vector index,
+		// and so do the rows written before sizes were configurable
+		sizes := []int{textEmbedder.Dimensions()}
+		if sizes[0] != embedder.DefaultDimensions {
+			sizes = append(sizes, embedder.DefaultDimensions)
+		}
+		for _, dimensions := range sizes {
+			indexCtx, indexCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			err = db.EnsureVectorIndex(indexCtx, dimensions)
+			indexCancel()
+			if err != nil {
+				utils.Zlog.Error("Failed to create vector index", zap.Int("dimensions", dimensions), zap.Error(err))
+				os.Exit(1)
+			}
+		}
+	}
+	ingestionService, workers := ingestion.NewIngestion(db, cfg, textEmbedder, dispatcher)
+	if cfg.RunsWorkers() {
+		workers.Start()
+		dispatcher.Start()
+	}
+
 	// Initialize router and routes
 	router := gin.New()
-	routes.SetupRoutes(router, db, cfg)
+	routes.SetupRoutes(router, db, cfg, ingestionService, dispatcher, textEmbedder)
 
 	// Create and start HTTP server
 	srv := &http.Server{
@@ -98,5 +179,142 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Let in-flight jobs finish; anything left keeps its lease and is retried elsewhere
+	workers.Stop(ctx)
+	dispatcher.Stop(ctx)
+
 	utils.Zlog.Info("Server exited")
 }
+
+// migrateUp applies pending migrations before the pool is created, for
+// deployments that set AUTO_MIGRATE=true
+func migrateUp(cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	migrator, err := loaders.NewMigrator(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close(context.Background())
+
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		utils.Zlog.Info("Applied migration", zap.Int("version", migration.Version), zap.String("name", migration.Name))
+	}
+	return err
+}
+
+// newEmbedder builds the embedder shared by the workers and search from the
+// configured provider and its fallbacks, or returns nil when it is not
+// configured
+func newEmbedder(cfg *config.Config) embedder.Embedder {
+	primary := newPrimaryEmbedder(cfg)
+	if primary == nil {
+		return nil
+	}
+
+	providers := []embedder.FailoverProvider{{Name: cfg.EmbeddingProvider, Embedder: primary}}
+	if len(cfg.GeminiFallbackAPIKeys) > 0 {
+		geminiEmbedder, err := embedder.NewGeminiEmbedder(cfg.GeminiFallbackAPIKeys, embeddingSettings(cfg), embedder.RateLimits{
+			RequestsPerMinute: cfg.GeminiRequestsPerMinute,
+			TokensPerMinute:   cfg.GeminiTokensPerMinute,
+		})
+		if err != nil {
+			utils.Zlog.Error("Failed to initialize fallback Gemini embedder", zap.Error(err))
+		} else {
+			providers = append(providers, embedder.FailoverProvider{Name: "gemini-fallback", Embedder: geminiEmbedder})
+		}
+	}
+	if cfg.EmbeddingFallbackBaseURL != "" {
+		model := cfg.EmbeddingFallbackModel
+		if model == "" {
+			model = cfg.EmbeddingModel
+		}
+		openAIEmbedder, err := embedder.NewOpenAIEmbedder(cfg.EmbeddingFallbackBaseURL, cfg.EmbeddingFallbackAPIKey, embedder.Settings{
+			Model:      model,
+			Dimensions: cfg.EmbeddingDimensions,
+		})
+		if err != nil {
+			utils.Zlog.Error("Failed to initialize fallback OpenAI-compatible embedder", zap.Error(err))
+		} else {
+			providers = append(providers, embedder.FailoverProvider{Name: "openai-fallback", Embedder: openAIEmbedder})
+		}
+	}
+	if len(providers) == 1 {
+		return primary
+	}
+
+	// A fallback writing vectors the primary's cannot be compared with would
+	// corrupt search, so refuse to start rather than run without it
+	failover, err := embedder.NewFailoverEmbedder(providers)
+	if err != nil {
+		utils.Zlog.Error("Invalid embedding fallback configuration", zap.Error(err))
+		os.Exit(1)
+	}
+	utils.Zlog.Info("Embedding failover enabled", zap.Int("providers", len(providers)))
+	return failover
+}
+
+// newPrimaryEmbedder builds the embedder for EMBEDDING_PROVIDER
+func newPrimaryEmbedder(cfg *config.Config) embedder.Embedder {
+	switch cfg.EmbeddingProvider {
+	case embedder.ProviderGemini:
+		if len(cfg.GeminiAPIKeys) == 0 {
+			utils.Zlog.Warn("No Gemini API keys provided, embedder will not be initialized")
+			return nil
+		}
+		geminiEmbedder, err := embedder.NewGeminiEmbedder(cfg.GeminiAPIKeys, embeddingSettings(cfg), embedder.RateLimits{
+			RequestsPerMinute: cfg.GeminiRequestsPerMinute,
+			TokensPerMinute:   cfg.GeminiTokensPerMinute,
+		})
+		if err != nil {
+			utils.Zlog.Error("Failed to initialize Gemini embedder", zap.Error(err))
+			return nil
+		}
+		utils.Zlog.Info("Gemini embedder initialized successfully",
+			zap.String("model", geminiEmbedder.ModelID()),
+			zap.String("taskType", geminiEmbedder.TaskType()),
+			zap.Int("dimensions", geminiEmbedder.Dimensions()),
+			zap.Int("apiKeyCount", len(cfg.GeminiAPIKeys)))
+		return geminiEmbedder
+
+	case embedder.ProviderOpenAI:
+		openAIEmbedder, err := embedder.NewOpenAIEmbedder(cfg.EmbeddingBaseURL, cfg.EmbeddingAPIKey, embeddingSettings(cfg))
+		if err != nil {
+			utils.Zlog.Error("Failed to initialize OpenAI-compatible embedder", zap.Error(err))
+			return nil
+		}
+		utils.Zlog.Info("OpenAI-compatible embedder initialized successfully",
+			zap.String("model", openAIEmbedder.ModelID()),
+			zap.Int("dimensions", openAIEmbedder.Dimensions()),
+			zap.String("baseUrl", cfg.EmbeddingBaseURL))
+		return openAIEmbedder
+
+	case embedder.ProviderHash:
+		hashEmbedder, err := embedder.NewHashEmbedder(embeddingSettings(cfg))
+		if err != nil {
+			utils.Zlog.Error("Failed to initialize hash embedder", zap.Error(err))
+			return nil
+		}
+		utils.Zlog.Warn("Hash embedder initialized; vectors are deterministic test data, not semantic embeddings",
+			zap.String("model", hashEmbedder.ModelID()),
+			zap.Int("dimensions", hashEmbedder.Dimensions()))
+		return hashEmbedder
+
+	default:
+		utils.Zlog.Error("Unknown EMBEDDING_PROVIDER, embedder will not be initialized",
+			zap.String("provider", cfg.EmbeddingProvider))
+		return nil
+	}
+}
+
+// embeddingSettings returns the global embedding settings from the EMBEDDING_*
+// variables; chatbots can override them
+func embeddingSettings(cfg *config.Config) embedder.Settings {
+	return embedder.Settings{
+		Model:      cfg.EmbeddingModel,
+		TaskType:   cfg.EmbeddingTaskType,
+		Dimensions: cfg.EmbeddingDimensions,
+	}
+}
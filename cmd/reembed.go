@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/config"
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/reembed"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+)
+
+const reembedUsage = `usage: reembed start [--chatbot id] [--model name] [--task-type type] [--dimensions n] [--batch-size n] [--cutover]
+       reembed resume [--batch-size n] [--cutover] <run-id>
+       reembed cutover <run-id>
+       reembed rollback <run-id>
+       reembed status [run-id]`
+
+// runReembed handles the `reembed` subcommands and returns the process exit
+// code. Interrupting a run leaves it resumable from its last saved batch.
+func runReembed(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Println(reembedUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("reembed "+args[0], flag.ContinueOnError)
+	chatbotID := fs.String("chatbot", "", "Chatbot to re-embed (defaults to every chatbot)")
+	model := fs.String("model", "", "Embedding model (defaults to EMBEDDING_MODEL)")
+	taskType := fs.String("task-type", "", "Embedding task type (defaults to EMBEDDING_TASK_TYPE)")
+	dimensions := fs.Int("dimensions", 0, "Embedding dimensions (defaults to EMBEDDING_DIMENSIONS)")
+	batchSize := fs.Int("batch-size", 100, "Rows embedded per batch")
+	cutover := fs.Bool("cutover", false, "Cut over as soon as every row is embedded")
+	if err := fs.Parse(args[1:]); err != nil {
+		fmt.Println(reembedUsage)
+		return 2
+	}
+
+	cleanup := utils.InitLogger(cfg)
+	defer cleanup()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := loaders.NewPostgresClient(cfg.DatabaseURL, 1, cfg.BatchSize)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	if err := db.CheckSchema(ctx); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	if args[0] == "status" {
+		return reembedStatus(ctx, db, fs.Arg(0))
+	}
+
+	// Rolling back only restores stored vectors and needs no embedder
+	var textEmbedder embedder.Embedder
+	var cache *embedder.Cache
+	if args[0] != "rollback" {
+		textEmbedder = newEmbedder(cfg)
+		if textEmbedder == nil {
+			fmt.Println("Error: embedder is not configured")
+			return 1
+		}
+		if cfg.EmbeddingCache {
+			cache = embedder.NewCache(db, 0)
+		}
+	}
+	runner := reembed.NewRunner(db, textEmbedder, cache, *batchSize)
+
+	switch args[0] {
+	case "start":
+		if fs.NArg() != 0 {
+			fmt.Println(reembedUsage)
+			return 2
+		}
+		run, err := runner.Start(ctx, *chatbotID, embedder.Settings{
+			Model:      *model,
+			TaskType:   *taskType,
+			Dimensions: *dimensions,
+		})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Started run %s: %d rows to embed with %s (%d dimensions)\n", run.ID, run.TotalRows, run.ModelID, run.TargetDimensions)
+		return reembedRun(ctx, runner, run.ID, *cutover)
+
+	case "resume":
+		if fs.NArg() != 1 {
+			fmt.Println(reembedUsage)
+			return 2
+		}
+		return reembedRun(ctx, runner, fs.Arg(0), *cutover)
+
+	case "cutover":
+		if fs.NArg() != 1 {
+			fmt.Println(reembedUsage)
+			return 2
+		}
+		return reembedRun(ctx, runner, fs.Arg(0), true)
+
+	case "rollback":
+		if fs.NArg() != 1 {
+			fmt.Println(reembedUsage)
+			return 2
+		}
+		result, err := runner.Rollback(ctx, fs.Arg(0))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Rolled back run %s: %d rows restored\n", fs.Arg(0), result.Reverted)
+		if result.Stranded > 0 {
+			fmt.Printf("Warning: %d rows written after cutover keep the run's model; re-embed them with a new run\n", result.Stranded)
+		}
+
+	default:
+		fmt.Println(reembedUsage)
+		return 2
+	}
+	return 0
+}
+
+// reembedRun embeds the run's remaining rows and, when cutover is set, swaps
+// them in
+func reembedRun(ctx context.Context, runner *reembed.Runner, runID string, cutover bool) int {
+	var run *types.ReembedRun
+	var err error
+	if cutover {
+		run, err = runner.Cutover(ctx, runID)
+	} else {
+		run, err = runner.Run(ctx, runID)
+	}
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf("Interrupted; continue with: reembed resume %s\n", runID)
+		return 1
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	if run.Status == types.ReembedStatusActive {
+		fmt.Printf("Run %s is active: %d rows now use %s\n", run.ID, run.EmbeddedRows, run.ModelID)
+	} else {
+		fmt.Printf("Run %s is ready: %d rows embedded; switch with: reembed cutover %s\n", run.ID, run.EmbeddedRows, run.ID)
+	}
+	return 0
+}
+
+// reembedStatus lists every run, or the details of one
+func reembedStatus(ctx context.Context, db *loaders.PostgresClient, runID string) int {
+	var runs []types.ReembedRun
+	if runID != "" {
+		run, err := db.GetReembedRun(ctx, runID)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		runs = append(runs, *run)
+	} else {
+		var err error
+		runs, err = db.ListReembedRuns(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCHATBOT\tMODEL\tDIMENSIONS\tSTATUS\tPROGRESS\tUPDATED AT")
+	for _, run := range runs {
+		chatbot := run.ChatbotID
+		if chatbot == "" {
+			chatbot = "all"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d/%d\t%s\n", run.ID, chatbot, run.ModelID, run.TargetDimensions,
+			run.Status, run.EmbeddedRows, run.TotalRows, run.UpdatedAt.UTC().Format(time.RFC3339))
+	}
+	w.Flush()
+
+	if runID != "" && runs[0].ErrorMessage != "" {
+		fmt.Printf("Error: %s\n", runs[0].ErrorMessage)
+	}
+	return 0
+}
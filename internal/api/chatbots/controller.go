@@ -0,0 +1,144 @@
+package chatbots
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+var validate = validator.New()
+
+// Controller handles HTTP requests for per-chatbot settings
+type Controller struct {
+	service *Service
+}
+
+// NewController creates a new chatbots controller
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// GetEmbeddingSettings godoc
+// @Summary Get a chatbot's embedding settings
+// @Description Return the model, task type and dimensions new chunks of the chatbot are embedded with, and its overrides of the global settings if any
+// @Tags chatbots
+// @Produce json
+// @Param chatbotId path string true "Chatbot ID"
+// @Success 200 {object} types.EmbeddingSettingsResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/chatbots/{chatbotId}/embedding-settings [get]
+func (ctrl *Controller) GetEmbeddingSettings(c *gin.Context) {
+	chatbotID := c.Param("chatbotId")
+
+	response, err := ctrl.service.GetEmbeddingSettings(c.Request.Context(), chatbotID)
+	if err != nil {
+		ctrl.fail(c, chatbotID, "Failed to get embedding settings", err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateEmbeddingSettings godoc
+// @Summary Override a chatbot's embedding settings
+// @Description Set the model, task type or dimensions new chunks of the chatbot are embedded with; omitted fields use the global settings. Existing chunks keep the model they were embedded with until re-ingested, and searches only consider chunks of the current settings.
+// @Tags chatbots
+// @Accept json
+// @Produce json
+// @Param chatbotId path string true "Chatbot ID"
+// @Param request body types.UpdateEmbeddingSettingsRequest true "Embedding settings"
+// @Success 200 {object} types.EmbeddingSettingsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/chatbots/{chatbotId}/embedding-settings [put]
+func (ctrl *Controller) UpdateEmbeddingSettings(c *gin.Context) {
+	chatbotID := c.Param("chatbotId")
+
+	var req types.UpdateEmbeddingSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   "invalid request: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := ctrl.service.UpdateEmbeddingSettings(c.Request.Context(), chatbotID, req)
+	if err != nil {
+		ctrl.fail(c, chatbotID, "Failed to update embedding settings", err)
+		return
+	}
+
+	utils.Zlog.Info("Updated chatbot embedding settings",
+		zap.String("chatbotId", chatbotID),
+		zap.String("model", response.ModelID),
+		zap.Int("dimensions", response.Dimensions))
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteEmbeddingSettings godoc
+// @Summary Remove a chatbot's embedding overrides
+// @Description Return the chatbot to the global embedding settings
+// @Tags chatbots
+// @Param chatbotId path string true "Chatbot ID"
+// @Success 204
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/chatbots/{chatbotId}/embedding-settings [delete]
+func (ctrl *Controller) DeleteEmbeddingSettings(c *gin.Context) {
+	chatbotID := c.Param("chatbotId")
+
+	if err := ctrl.service.DeleteEmbeddingSettings(c.Request.Context(), chatbotID); err != nil {
+		ctrl.fail(c, chatbotID, "Failed to delete embedding settings", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// fail maps service errors to responses
+func (ctrl *Controller) fail(c *gin.Context, chatbotID, logMessage string, err error) {
+	switch {
+	case errors.Is(err, ErrEmbedderUnavailable):
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error:     "Service Unavailable",
+			Message:   "No embedder is configured",
+			Timestamp: time.Now().UTC(),
+		})
+	case errors.Is(err, ErrInvalidSettings):
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+	case errors.Is(err, loaders.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:     "Not Found",
+			Message:   "Chatbot has no embedding overrides",
+			Timestamp: time.Now().UTC(),
+		})
+	default:
+		utils.Zlog.Error(logMessage, zap.String("chatbotId", chatbotID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+	}
+}
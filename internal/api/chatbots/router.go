@@ -0,0 +1,14 @@
+package chatbots
+
+import (
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, db *loaders.PostgresClient, textEmbedder embedder.Embedder) {
+	controller := NewController(NewService(db, textEmbedder))
+	router.GET("/chatbots/:chatbotId/embedding-settings", controller.GetEmbeddingSettings)
+	router.PUT("/chatbots/:chatbotId/embedding-settings", controller.UpdateEmbeddingSettings)
+	router.DELETE("/chatbots/:chatbotId/embedding-settings", controller.DeleteEmbeddingSettings)
+}
@@ -0,0 +1,101 @@
+package chatbots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+var (
+	// ErrEmbedderUnavailable is returned when no embedder is configured to apply settings to
+	ErrEmbedderUnavailable = errors.New("embedder not configured")
+	// ErrInvalidSettings is returned when the embedder rejects the requested settings
+	ErrInvalidSettings = errors.New("invalid embedding settings")
+)
+
+type Service struct {
+	db       *loaders.PostgresClient
+	embedder embedder.Embedder
+}
+
+func NewService(db *loaders.PostgresClient, textEmbedder embedder.Embedder) *Service {
+	return &Service{db: db, embedder: textEmbedder}
+}
+
+// GetEmbeddingSettings returns the settings the chatbot's chunks are embedded with
+func (s *Service) GetEmbeddingSettings(ctx context.Context, chatbotID string) (*types.EmbeddingSettingsResponse, error) {
+	if s.embedder == nil {
+		return nil, ErrEmbedderUnavailable
+	}
+
+	stored, err := s.db.GetChatbotEmbeddingSettings(ctx, chatbotID)
+	if err != nil {
+		return nil, err
+	}
+	textEmbedder := s.embedder
+	if stored != nil {
+		textEmbedder, err = s.embedder.WithSettings(embedder.Settings{
+			Model:      stored.Model,
+			TaskType:   stored.TaskType,
+			Dimensions: stored.Dimensions,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+		}
+	}
+	return settingsResponse(chatbotID, textEmbedder, stored), nil
+}
+
+// UpdateEmbeddingSettings validates and stores the chatbot's overrides, and
+// makes sure the vector index for its dimensions exists before chunks of
+// that size are written. Existing chunks keep their model until re-ingested.
+func (s *Service) UpdateEmbeddingSettings(ctx context.Context, chatbotID string, req types.UpdateEmbeddingSettingsRequest) (*types.EmbeddingSettingsResponse, error) {
+	if s.embedder == nil {
+		return nil, ErrEmbedderUnavailable
+	}
+
+	textEmbedder, err := s.embedder.WithSettings(embedder.Settings{
+		Model:      req.Model,
+		TaskType:   req.TaskType,
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+
+	if err := s.db.EnsureVectorIndex(ctx, textEmbedder.Dimensions()); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.db.UpsertChatbotEmbeddingSettings(ctx, types.ChatbotEmbeddingSettings{
+		ChatbotID:  chatbotID,
+		Model:      req.Model,
+		TaskType:   req.TaskType,
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return settingsResponse(chatbotID, textEmbedder, stored), nil
+}
+
+// DeleteEmbeddingSettings returns the chatbot to the global settings
+func (s *Service) DeleteEmbeddingSettings(ctx context.Context, chatbotID string) error {
+	return s.db.DeleteChatbotEmbeddingSettings(ctx, chatbotID)
+}
+
+func settingsResponse(chatbotID string, textEmbedder embedder.Embedder, stored *types.ChatbotEmbeddingSettings) *types.EmbeddingSettingsResponse {
+	return &types.EmbeddingSettingsResponse{
+		ChatbotID:  chatbotID,
+		ModelID:    textEmbedder.ModelID(),
+		TaskType:   textEmbedder.TaskType(),
+		Dimensions: textEmbedder.Dimensions(),
+		Override:   stored,
+		Timestamp:  time.Now().UTC(),
+	}
+}
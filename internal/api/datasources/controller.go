@@ -0,0 +1,52 @@
+package datasources
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Controller handles HTTP requests for datasources
+type Controller struct {
+	service *Service
+}
+
+// NewController creates a new datasources controller
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Delete godoc
+// @Summary Purge a datasource's embeddings
+// @Description Delete every embedding stored for a datasource. Deleting a datasource without embeddings succeeds with a count of 0.
+// @Tags datasources
+// @Produce json
+// @Param id path string true "Datasource ID"
+// @Success 200 {object} types.DeleteDatasourceResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/datasources/{id} [delete]
+func (ctrl *Controller) Delete(c *gin.Context) {
+	datasourceID := c.Param("id")
+
+	response, err := ctrl.service.Delete(c.Request.Context(), datasourceID)
+	if err != nil {
+		utils.Zlog.Error("Failed to delete datasource embeddings",
+			zap.String("datasourceId", datasourceID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	utils.Zlog.Info("Deleted datasource embeddings",
+		zap.String("datasourceId", datasourceID),
+		zap.Int64("deleted", response.DeletedEmbeddings))
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,11 @@
+package datasources
+
+import (
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, db *loaders.PostgresClient) {
+	controller := NewController(NewService(db))
+	router.DELETE("/datasources/:id", controller.Delete)
+}
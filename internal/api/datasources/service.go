@@ -0,0 +1,30 @@
+package datasources
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+type Service struct {
+	db *loaders.PostgresClient
+}
+
+func NewService(db *loaders.PostgresClient) *Service {
+	return &Service{db: db}
+}
+
+// Delete purges every embedding of a datasource
+func (s *Service) Delete(ctx context.Context, datasourceID string) (*types.DeleteDatasourceResponse, error) {
+	deleted, err := s.db.DeleteDatasourceEmbeddings(ctx, datasourceID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.DeleteDatasourceResponse{
+		DatasourceID:      datasourceID,
+		DeletedEmbeddings: deleted,
+		Timestamp:         time.Now().UTC(),
+	}, nil
+}
@@ -2,12 +2,16 @@ package ingestion
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/Conversly/db-ingestor/internal/loaders"
 	"github.com/Conversly/db-ingestor/internal/types"
 	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -29,8 +33,10 @@ func NewController(service *Service) *Controller {
 // @Accept json
 // @Produce json
 // @Param request body types.ProcessRequest true "Process Request"
+// @Param Idempotency-Key header string false "Return the original response for duplicate submissions"
 // @Success 200 {object} ProcessResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/process [post]
 func (ctrl *Controller) Process(c *gin.Context) {
@@ -61,6 +67,20 @@ func (ctrl *Controller) Process(c *gin.Context) {
 		return
 	}
 
+	// Spool files can only be referenced by the upload endpoint that wrote them
+	if len(req.Uploads) > 0 {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   "uploads cannot be set directly, use /api/v1/process/upload",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	if !ctrl.prepare(c, &req) {
+		return
+	}
+
 	// Validate request using the validator
 	if err := ValidateProcessRequest(&req); err != nil {
 		utils.Zlog.Error("Validation failed", zap.Error(err))
@@ -73,6 +93,46 @@ func (ctrl *Controller) Process(c *gin.Context) {
 	}
 
 	response, err := ctrl.service.Process(c.Request.Context(), req)
+	ctrl.respond(c, response, err)
+}
+
+// prepare applies request headers and checks options this deployment must
+// support. It writes the error response and returns false on failure.
+func (ctrl *Controller) prepare(c *gin.Context, req *types.ProcessRequest) bool {
+	// The Idempotency-Key header and the idempotencyKey field are interchangeable
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		if req.IdempotencyKey != "" && req.IdempotencyKey != key {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:     "Bad Request",
+				Message:   "Idempotency-Key header does not match idempotencyKey",
+				Timestamp: time.Now().UTC(),
+			})
+			return false
+		}
+		req.IdempotencyKey = key
+	}
+
+	if req.CallbackURL != "" && !ctrl.service.SupportsCallbacks() {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   "callbackUrl is not supported: WEBHOOK_SECRET is not configured",
+			Timestamp: time.Now().UTC(),
+		})
+		return false
+	}
+	return true
+}
+
+// respond writes the outcome of Service.Process
+func (ctrl *Controller) respond(c *gin.Context, response *types.ProcessResponse, err error) {
+	if errors.Is(err, ErrIdempotencyConflict) {
+		c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:     "Conflict",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
 	if err != nil {
 		utils.Zlog.Error("Failed to process sources", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
@@ -84,3 +144,208 @@ func (ctrl *Controller) Process(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+// GetJob godoc
+// @Summary Get ingestion job status
+// @Description Get the persisted status and per-source results of an ingestion job
+// @Tags ingestion
+// @Produce json
+// @Param jobId path string true "Job ID"
+// @Success 200 {object} types.IngestionRecord
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/jobs/{jobId} [get]
+func (ctrl *Controller) GetJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	job, err := ctrl.service.GetJob(c.Request.Context(), jobID)
+	if errors.Is(err, loaders.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:     "Not Found",
+			Message:   "Job not found",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		utils.Zlog.Error("Failed to load job", zap.String("jobId", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob godoc
+// @Summary Cancel an ingestion job
+// @Description Stop downloads, processing and embedding for a job and mark its unfinished datasources CANCELLED
+// @Tags ingestion
+// @Produce json
+// @Param jobId path string true "Job ID"
+// @Success 200 {object} types.IngestionRecord
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/jobs/{jobId} [delete]
+func (ctrl *Controller) CancelJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	job, err := ctrl.service.CancelJob(c.Request.Context(), jobID)
+	switch {
+	case errors.Is(err, loaders.ErrNotFound):
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:     "Not Found",
+			Message:   "Job not found",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	case errors.Is(err, loaders.ErrJobFinished):
+		c.JSON(http.StatusConflict, types.ErrorResponse{
+			Error:     "Conflict",
+			Message:   "Job has already finished",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	case err != nil:
+		utils.Zlog.Error("Failed to cancel job", zap.String("jobId", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+const (
+	eventPollInterval = time.Second
+	eventBatchSize    = 100
+	eventKeepAlive    = 15 * time.Second
+)
+
+// StreamEvents godoc
+// @Summary Stream ingestion job progress
+// @Description Server-sent events with per-datasource progress of a job. Reconnecting clients resume after Last-Event-ID. The stream ends with a terminal event carrying the final job.
+// @Tags ingestion
+// @Produce text/event-stream
+// @Param jobId path string true "Job ID"
+// @Param Last-Event-ID header string false "Resume after this event ID"
+// @Success 200 {object} types.JobEvent
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/jobs/{jobId}/events [get]
+func (ctrl *Controller) StreamEvents(c *gin.Context) {
+	jobID := c.Param("jobId")
+	ctx := c.Request.Context()
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	var afterID int64
+	if lastEventID != "" {
+		parsed, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, types.ErrorResponse{
+				Error:     "Bad Request",
+				Message:   "Last-Event-ID must be a non-negative integer",
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+		afterID = parsed
+	}
+
+	if _, err := ctrl.service.GetJob(ctx, jobID); err != nil {
+		if errors.Is(err, loaders.ErrNotFound) {
+			c.JSON(http.StatusNotFound, types.ErrorResponse{
+				Error:     "Not Found",
+				Message:   "Job not found",
+				Timestamp: time.Now().UTC(),
+			})
+			return
+		}
+		utils.Zlog.Error("Failed to load job", zap.String("jobId", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// The server's write timeout is meant for regular requests, not streams
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		utils.Zlog.Warn("Failed to clear write deadline for event stream", zap.Error(err))
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+
+	// Events sent recently, so late commits with lower IDs are sent once. A
+	// reconnecting client may see some of those again.
+	sentAt := make(map[int64]time.Time)
+
+	c.Stream(func(w io.Writer) bool {
+		sent := make([]int64, 0, len(sentAt))
+		for id, at := range sentAt {
+			if time.Since(at) > 2*jobEventLookback {
+				delete(sentAt, id)
+				continue
+			}
+			sent = append(sent, id)
+		}
+
+		job, events, err := ctrl.service.JobEvents(ctx, jobID, afterID, sent, eventBatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				utils.Zlog.Error("Failed to load job events", zap.String("jobId", jobID), zap.Error(err))
+				c.SSEvent("error", gin.H{"message": "failed to load job events"})
+			}
+			return false
+		}
+
+		for _, event := range events {
+			// The SSE id only moves forward so Last-Event-ID resumes after
+			// everything sent, including events that committed late
+			afterID = max(afterID, event.ID)
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatInt(afterID, 10),
+				Event: event.Type,
+				Data:  event,
+			})
+			sentAt[event.ID] = time.Now()
+			lastWrite = time.Now()
+		}
+		if len(events) == eventBatchSize {
+			return true
+		}
+
+		if job.CompletedAt != nil || job.Status == types.StatusCancelled {
+			c.SSEvent(types.JobEventTerminal, job)
+			return false
+		}
+
+		if time.Since(lastWrite) >= eventKeepAlive {
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			return true
+		}
+	})
+}
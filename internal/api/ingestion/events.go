@@ -0,0 +1,43 @@
+package ingestion
+
+import (
+	"context"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"go.uber.org/zap"
+)
+
+// recordEvent stores a progress event for GET /jobs/:jobId/events. A lost event
+// only costs subscribers a progress update, so failures are logged and ignored.
+func recordEvent(ctx context.Context, db *loaders.PostgresClient, jobID, eventType, datasourceID string, data map[string]interface{}) {
+	if db == nil || jobID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := db.AppendIngestionJobEvent(ctx, jobID, eventType, datasourceID, data); err != nil {
+		utils.Zlog.Warn("Failed to record ingestion job event",
+			zap.String("jobId", jobID),
+			zap.String("event", eventType),
+			zap.Error(err))
+	}
+}
+
+func (s *Service) sourceStarted(ctx context.Context, jobID, datasourceID, source string) {
+	recordEvent(ctx, s.db, jobID, types.JobEventSourceStarted, datasourceID, map[string]interface{}{
+		"source": source,
+	})
+}
+
+func (s *Service) sourceProcessed(ctx context.Context, jobID string, result types.SourceResult) {
+	recordEvent(ctx, s.db, jobID, types.JobEventSourceProcessed, result.DatasourceID, map[string]interface{}{
+		"source":     result.Source,
+		"sourceType": result.SourceType,
+		"status":     result.Status,
+		"chunkCount": result.ChunkCount,
+		"error":      result.Error,
+	})
+}
@@ -0,0 +1,124 @@
+package ingestion
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// previewTimeout bounds how long a preview may spend downloading and chunking
+const previewTimeout = 2 * time.Minute
+
+// Preview runs the request's sources through the same processors and citation
+// step as an ingestion job and returns the chunks. It uses a Service without a
+// database or workers, so nothing is recorded, embedded or persisted.
+func (s *Service) Preview(ctx context.Context, req types.ProcessRequest) *types.PreviewResponse {
+	start := time.Now()
+	dryRun := &Service{}
+
+	results, totalChunks, chunks := dryRun.processAllSources(ctx, req, "")
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].DatasourceID < results[j].DatasourceID
+	})
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if chunks[i].DatasourceID != chunks[j].DatasourceID {
+			return chunks[i].DatasourceID < chunks[j].DatasourceID
+		}
+		return chunks[i].ChunkIndex < chunks[j].ChunkIndex
+	})
+
+	failed := 0
+	for _, result := range results {
+		if result.Status != types.SourceStatusSuccess {
+			failed++
+		}
+	}
+	successful := len(results) - failed
+
+	var status types.ProcessStatus
+	if failed == 0 {
+		status = types.StatusCompleted
+	} else if successful == 0 {
+		status = types.StatusFailed
+	} else {
+		status = types.StatusPartial
+	}
+
+	if chunks == nil {
+		chunks = []types.ContentChunk{}
+	}
+	config := chunkingConfig(req)
+	return &types.PreviewResponse{
+		Status: status,
+		Options: types.ProcessingOptions{
+			ChunkSize:    config.ChunkSize,
+			ChunkOverlap: config.ChunkOverlap,
+			ChunkUnit:    config.ChunkUnit,
+		},
+		TotalSources:     s.calculateTotalSources(req),
+		ProcessedSources: successful,
+		FailedSources:    failed,
+		TotalChunks:      totalChunks,
+		Results:          results,
+		Chunks:           chunks,
+		Duration:         time.Since(start).String(),
+		Timestamp:        time.Now().UTC(),
+	}
+}
+
+// Preview godoc
+// @Summary Preview chunking of data sources
+// @Description Run the processors synchronously and return chunks, metadata and citations without embedding or persisting anything
+// @Tags ingestion
+// @Accept json
+// @Produce json
+// @Param request body types.ProcessRequest true "Process Request"
+// @Success 200 {object} types.PreviewResponse
+// @Failure 400 {object} ErrorResponse
+// @Router /api/v1/preview [post]
+func (ctrl *Controller) Preview(c *gin.Context) {
+	var req types.ProcessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	if len(req.Uploads) > 0 {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   "uploads cannot be previewed, send the document as textContent or documents",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	if err := ValidateProcessRequest(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// Downloads and crawls can outlast the server's write timeout for regular requests
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(previewTimeout + 10*time.Second)); err != nil {
+		utils.Zlog.Warn("Failed to extend write deadline for preview", zap.Error(err))
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), previewTimeout)
+	defer cancel()
+
+	c.JSON(http.StatusOK, ctrl.service.Preview(ctx, req))
+}
@@ -5,39 +5,51 @@ import (
 	"github.com/Conversly/db-ingestor/internal/embedder"
 	"github.com/Conversly/db-ingestor/internal/loaders"
 	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-func RegisterRoutes(router *gin.RouterGroup, db *loaders.PostgresClient, cfg *config.Config) {
-	queueCapacity := cfg.BatchSize * cfg.WorkerCount
-	if queueCapacity <= 0 {
-		queueCapacity = 100
-	}
+// NewIngestion builds the service and worker pool shared by the api and worker
+// roles. API processes only enqueue through the pool; the caller starts the
+// workers when this process consumes jobs.
+func NewIngestion(db *loaders.PostgresClient, cfg *config.Config, textEmbedder embedder.Embedder, dispatcher *webhooks.Dispatcher) (*Service, *WorkerPool) {
+	queueOptions := QueueOptions{
+		PollInterval:  cfg.QueuePollInterval,
+		LeaseDuration: cfg.QueueLeaseDuration,
+		MaxAttempts:   cfg.QueueMaxAttempts,
 
-	var geminiEmbedder *embedder.GeminiEmbedder
-	if len(cfg.GeminiAPIKeys) > 0 {
-		var err error
-		geminiEmbedder, err = embedder.NewGeminiEmbedder(cfg.GeminiAPIKeys)
-		if err != nil {
-			utils.Zlog.Error("Failed to initialize Gemini embedder", zap.Error(err))
-			geminiEmbedder = nil
-		} else {
-			utils.Zlog.Info("Gemini embedder initialized successfully", zap.Int("apiKeyCount", len(cfg.GeminiAPIKeys)))
-		}
-	} else {
-		utils.Zlog.Warn("No Gemini API keys provided, embedder will not be initialized")
+		EventRetention: cfg.JobEventRetention,
+		DeadRetention:  cfg.DeadWorkRetention,
+	}
+	workers := NewWorkerPool(cfg.WorkerCount, queueOptions, textEmbedder, db)
+	spool, err := utils.NewFileSpool(cfg.UploadSpoolDir, cfg.UploadMaxBytes)
+	if err != nil {
+		utils.Zlog.Error("Failed to initialize upload spool, uploads are disabled", zap.Error(err))
+		spool = nil
 	}
 
-	workers := NewWorkerPool(cfg.WorkerCount, queueCapacity, geminiEmbedder, db)
-	service := NewService(db, workers)
+	workers.SetDispatcher(dispatcher)
+	workers.SetUploadSpool(spool)
+	if cfg.EmbeddingCache && db != nil {
+		workers.SetEmbeddingCache(embedder.NewCache(db, cfg.EmbeddingCacheLRUSize))
+	}
+	service := NewService(db, workers, dispatcher, cfg.IdempotencyWindow, spool)
 
 	// Wire up the ingestion processor so workers can call it
 	workers.SetProcessFunc(service.ProcessIngestionJob)
-	workers.Start()
 
+	return service, workers
+}
+
+func RegisterRoutes(router *gin.RouterGroup, service *Service) {
 	controller := NewController(service)
 	router.POST("/process", controller.Process)
+	router.POST("/process/upload", controller.ProcessUpload)
+	router.POST("/preview", controller.Preview)
+	router.GET("/jobs/:jobId", controller.GetJob)
+	router.GET("/jobs/:jobId/events", controller.StreamEvents)
+	router.DELETE("/jobs/:jobId", controller.CancelJob)
 }
 
 // limitwas 3
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/Conversly/db-ingestor/internal/processors"
 	"github.com/Conversly/db-ingestor/internal/types"
 	"github.com/go-playground/validator/v10"
 )
@@ -17,10 +18,32 @@ func ValidateProcessRequest(r *types.ProcessRequest) error {
 	}
 
 	// At least one source must be present
-	if len(r.WebsiteURLs) == 0 && len(r.QandAData) == 0 && len(r.Documents) == 0 && len(r.TextContent) == 0 {
+	if len(r.WebsiteURLs) == 0 && len(r.QandAData) == 0 && len(r.Documents) == 0 && len(r.TextContent) == 0 && len(r.Uploads) == 0 {
 		return errors.New("at least one data source must be provided (websiteUrls, qandaData, documents, or textContent)")
 	}
 
+	return validateChunkUnit(r)
+}
+
+// validateChunkUnit rejects chunkUnit for sources that are never split by
+// size, where it would be silently ignored
+func validateChunkUnit(r *types.ProcessRequest) error {
+	if r.Options == nil || r.Options.ChunkUnit == "" {
+		return nil
+	}
+	if len(r.QandAData) > 0 {
+		return errors.New("options.chunkUnit does not apply to qandaData, each pair is one chunk")
+	}
+	for _, doc := range r.Documents {
+		if !processors.ChunkedBySize(doc.Pathname, doc.ContentType) {
+			return fmt.Errorf("options.chunkUnit does not apply to %s, CSV rows and markdown sections are not split by size", doc.Pathname)
+		}
+	}
+	for _, upload := range r.Uploads {
+		if !processors.ChunkedBySize(upload.Filename, upload.ContentType) {
+			return fmt.Errorf("options.chunkUnit does not apply to %s, CSV rows and markdown sections are not split by size", upload.Filename)
+		}
+	}
 	return nil
 }
 
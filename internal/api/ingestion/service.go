@@ -2,6 +2,10 @@ package ingestion
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -10,21 +14,60 @@ import (
 	"github.com/Conversly/db-ingestor/internal/processors"
 	"github.com/Conversly/db-ingestor/internal/types"
 	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// ErrIdempotencyConflict is returned when an idempotency key is reused with a different payload
+var ErrIdempotencyConflict = errors.New("idempotency key was already used with a different request payload")
+
 type Service struct {
-	db      *loaders.PostgresClient
-	workers *WorkerPool
+	db                *loaders.PostgresClient
+	workers           *WorkerPool
+	dispatcher        *webhooks.Dispatcher
+	idempotencyWindow time.Duration
+	spool             *utils.FileSpool
+}
+
+func NewService(db *loaders.PostgresClient, workers *WorkerPool, dispatcher *webhooks.Dispatcher, idempotencyWindow time.Duration, spool *utils.FileSpool) *Service {
+	return &Service{db: db, workers: workers, dispatcher: dispatcher, idempotencyWindow: idempotencyWindow, spool: spool}
 }
 
-func NewService(db *loaders.PostgresClient, workers *WorkerPool) *Service {
-	return &Service{db: db, workers: workers}
+// Spool returns the store for uploaded files, nil when uploads are unavailable
+func (s *Service) Spool() *utils.FileSpool {
+	return s.spool
 }
 
-// Process enqueues the request for background processing and returns immediately
+// SupportsCallbacks reports whether requests may set a callbackUrl
+func (s *Service) SupportsCallbacks() bool {
+	return s.dispatcher.Enabled()
+}
+
+// Process enqueues the request for background processing and returns immediately.
+// A request repeating an idempotency key seen within the idempotency window gets
+// the original response instead of a new job.
 func (s *Service) Process(ctx context.Context, req types.ProcessRequest) (*types.ProcessResponse, error) {
+	// Uploaded files belong to the job from here on; drop them if none is enqueued
+	enqueued := false
+	defer func() {
+		if !enqueued {
+			s.removeUploads(req)
+		}
+	}()
+
+	var requestHash string
+	if req.IdempotencyKey != "" {
+		var err error
+		requestHash, err = hashProcessRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if response, err := s.replayIdempotent(ctx, req, requestHash); err == nil || !errors.Is(err, loaders.ErrNotFound) {
+			return response, err
+		}
+	}
+
 	jobID := uuid.New().String()
 
 	utils.Zlog.Info("Enqueueing ingestion job",
@@ -34,7 +77,55 @@ func (s *Service) Process(ctx context.Context, req types.ProcessRequest) (*types
 		zap.Int("websites", len(req.WebsiteURLs)),
 		zap.Int("qanda", len(req.QandAData)),
 		zap.Int("documents", len(req.Documents)),
-		zap.Int("textContent", len(req.TextContent)))
+		zap.Int("textContent", len(req.TextContent)),
+		zap.Int("uploads", len(req.Uploads)))
+
+	totalSources := s.calculateTotalSources(req)
+	response := &types.ProcessResponse{
+		JobID:        jobID,
+		Status:       types.StatusPending,
+		Message:      "Job queued for processing",
+		TotalSources: totalSources,
+		Timestamp:    time.Now().UTC(),
+	}
+
+	record := types.IngestionRecord{
+		ID:             jobID,
+		UserID:         req.UserID,
+		ChatbotID:      req.ChatbotID,
+		Status:         types.StatusPending,
+		TotalSources:   totalSources,
+		DatasourceIDs:  collectDatasourceIDs(req),
+		CallbackURL:    req.CallbackURL,
+		IdempotencyKey: req.IdempotencyKey,
+		RequestHash:    requestHash,
+		Metadata: map[string]interface{}{
+			"websites":    len(req.WebsiteURLs),
+			"qanda":       len(req.QandAData),
+			"documents":   len(req.Documents),
+			"textContent": len(req.TextContent),
+			"uploads":     len(req.Uploads),
+			"mode":        writeMode(req),
+		},
+	}
+	if req.IdempotencyKey != "" {
+		stored, err := json.Marshal(response)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode response: %w", err)
+		}
+		record.Response = stored
+	}
+	if err := s.db.CreateIngestionJob(ctx, record); err != nil {
+		if errors.Is(err, loaders.ErrIdempotencyKeyExists) {
+			// A concurrent submission with the same key won the insert
+			response, err := s.replayIdempotent(ctx, req, requestHash)
+			if errors.Is(err, loaders.ErrNotFound) {
+				return nil, fmt.Errorf("a request with this idempotency key is in progress, try again later")
+			}
+			return response, err
+		}
+		return nil, err
+	}
 
 	job := IngestionJob{
 		JobID:   jobID,
@@ -42,18 +133,140 @@ func (s *Service) Process(ctx context.Context, req types.ProcessRequest) (*types
 	}
 
 	if ok := s.workers.EnqueueIngestion(job); !ok {
-		return nil, fmt.Errorf("ingestion queue is full, try again later")
+		if err := s.db.UpdateIngestionJobStatus(ctx, jobID, types.StatusFailed, "failed to enqueue ingestion job"); err != nil {
+			utils.Zlog.Error("Failed to mark ingestion job as failed",
+				zap.String("jobId", jobID),
+				zap.Error(err))
+		}
+		if req.IdempotencyKey != "" {
+			if err := s.db.ReleaseIdempotencyKey(ctx, jobID); err != nil {
+				utils.Zlog.Error("Failed to release idempotency key",
+					zap.String("jobId", jobID),
+					zap.Error(err))
+			}
+		}
+		return nil, fmt.Errorf("failed to enqueue ingestion job, try again later")
 	}
 
-	response := &types.ProcessResponse{
-		JobID:        jobID,
-		Status:       types.StatusProcessing,
-		Message:      "Job queued for processing",
-		TotalSources: s.calculateTotalSources(req),
-		Timestamp:    time.Now().UTC(),
+	enqueued = true
+	return response, nil
+}
+
+// replayIdempotent returns the stored response of the job holding the request's
+// idempotency key, ErrIdempotencyConflict if that job was created from a
+// different payload, or ErrNotFound if the key is free
+func (s *Service) replayIdempotent(ctx context.Context, req types.ProcessRequest, requestHash string) (*types.ProcessResponse, error) {
+	existing, err := s.db.FindIdempotentJob(ctx, req.ChatbotID, req.IdempotencyKey, s.idempotencyWindow)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyConflict
 	}
 
-	return response, nil
+	var response types.ProcessResponse
+	if err := json.Unmarshal(existing.Response, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode stored response: %w", err)
+	}
+
+	utils.Zlog.Info("Returning response of idempotent job",
+		zap.String("jobId", existing.ID),
+		zap.String("chatbotId", req.ChatbotID),
+		zap.String("idempotencyKey", req.IdempotencyKey))
+	return &response, nil
+}
+
+// hashProcessRequest fingerprints the payload a key was first used with. The
+// key itself is left out so the header and body forms hash the same, and so
+// are spool file names, which differ every time the same files are uploaded.
+func hashProcessRequest(req types.ProcessRequest) (string, error) {
+	req.IdempotencyKey = ""
+	uploads := make([]types.UploadedDocument, len(req.Uploads))
+	for i, upload := range req.Uploads {
+		upload.SpoolFile = ""
+		uploads[i] = upload
+	}
+	req.Uploads = uploads
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GetJob returns the persisted state of an ingestion job
+func (s *Service) GetJob(ctx context.Context, jobID string) (*types.IngestionRecord, error) {
+	return s.db.GetIngestionJob(ctx, jobID)
+}
+
+// jobEventLookback bounds how long after its creation an event may commit and
+// still be picked up by a stream that already moved past its ID. Events are
+// appended in single-statement transactions, so they commit well within it.
+const jobEventLookback = 10 * time.Second
+
+// JobEvents returns up to limit progress events after afterID, and those
+// created within jobEventLookback that are not in sent, together with the job
+// state read before them. Once that state is finished, every event of the job
+// is among those returned by this and earlier calls.
+func (s *Service) JobEvents(ctx context.Context, jobID string, afterID int64, sent []int64, limit int) (*types.IngestionRecord, []types.JobEvent, error) {
+	job, err := s.db.GetIngestionJob(ctx, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := s.db.ListIngestionJobEvents(ctx, jobID, afterID, jobEventLookback, sent, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, events, nil
+}
+
+// CancelJob stops an ingestion job: queued work is dropped, running work is
+// cancelled and every datasource without a final outcome is marked CANCELLED
+func (s *Service) CancelJob(ctx context.Context, jobID string) (*types.IngestionRecord, error) {
+	datasourceIDs, err := s.db.CancelIngestionJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := s.db.DeleteQueuedWork(ctx, jobID)
+	if err != nil {
+		utils.Zlog.Error("Failed to drop queued work for cancelled job",
+			zap.String("jobId", jobID),
+			zap.Error(err))
+	}
+
+	running := 0
+	if s.workers != nil {
+		running = s.workers.CancelJob(jobID)
+	}
+
+	// Finished or retried batches may already have persisted part of the job's
+	// rows; the cancellation holds the job row, so no later batch adds more
+	for _, datasourceID := range datasourceIDs {
+		if _, err := s.db.DeleteIngestionJobEmbeddings(ctx, jobID, datasourceID); err != nil {
+			utils.Zlog.Error("Failed to discard partial embeddings of cancelled job",
+				zap.String("jobId", jobID),
+				zap.String("datasourceId", datasourceID),
+				zap.Error(err))
+		}
+	}
+
+	if err := s.db.UpdateDataSourceStatus(ctx, datasourceIDs, "CANCELLED"); err != nil {
+		utils.Zlog.Error("Failed to update datasource status to CANCELLED",
+			zap.String("jobId", jobID),
+			zap.Error(err))
+	}
+
+	utils.Zlog.Info("Ingestion job cancelled",
+		zap.String("jobId", jobID),
+		zap.Int64("droppedItems", dropped),
+		zap.Int("runningItems", running),
+		zap.Int("cancelledDatasources", len(datasourceIDs)))
+
+	s.dispatcher.NotifyJob(ctx, jobID)
+
+	return s.db.GetIngestionJob(ctx, jobID)
 }
 
 // ProcessIngestionJob processes an ingestion job in the background (called by workers)
@@ -61,6 +274,27 @@ func (s *Service) ProcessIngestionJob(ctx context.Context, job IngestionJob) {
 	req := job.Request
 	jobID := job.JobID
 
+	// A crash or lost lease before this returns leaves the uploads for the retry
+	defer func() {
+		if !errors.Is(context.Cause(ctx), errLeaseLost) {
+			s.removeUploads(req)
+		}
+	}()
+
+	// A redelivered item whose job got past processing has already enqueued
+	// its embedding work
+	record, err := s.db.GetIngestionJob(ctx, jobID)
+	if err != nil {
+		utils.Zlog.Error("Failed to load ingestion job",
+			zap.String("jobId", jobID),
+			zap.Error(err))
+	} else if record.Status != types.StatusPending && record.Status != types.StatusProcessing {
+		utils.Zlog.Info("Ingestion job already processed, skipping redelivered item",
+			zap.String("jobId", jobID),
+			zap.String("status", string(record.Status)))
+		return
+	}
+
 	utils.Zlog.Info("Processing ingestion job",
 		zap.String("jobId", jobID),
 		zap.String("userId", req.UserID),
@@ -68,14 +302,25 @@ func (s *Service) ProcessIngestionJob(ctx context.Context, job IngestionJob) {
 		zap.Int("websites", len(req.WebsiteURLs)),
 		zap.Int("qanda", len(req.QandAData)),
 		zap.Int("documents", len(req.Documents)),
-		zap.Int("textContent", len(req.TextContent)))
+		zap.Int("textContent", len(req.TextContent)),
+		zap.Int("uploads", len(req.Uploads)))
+
+	if err := s.db.UpdateIngestionJobStatus(ctx, jobID, types.StatusProcessing, ""); err != nil {
+		utils.Zlog.Error("Failed to mark ingestion job as processing",
+			zap.String("jobId", jobID),
+			zap.Error(err))
+	}
 
 	results, totalChunks, allChunks := s.processAllSources(ctx, req, jobID)
+	if ctx.Err() != nil {
+		utils.Zlog.Info("Ingestion job cancelled during processing", zap.String("jobId", jobID))
+		return
+	}
 
 	successful := 0
 	failed := 0
 	for _, result := range results {
-		if result.Status == "success" {
+		if result.Status == types.SourceStatusSuccess {
 			successful++
 		} else {
 			failed++
@@ -91,40 +336,92 @@ func (s *Service) ProcessIngestionJob(ctx context.Context, job IngestionJob) {
 		status = types.StatusPartial
 	}
 
-	if s.workers != nil && len(allChunks) > 0 {
-		// Group chunks by datasourceID for parallel processing
-		chunksByDatasource := make(map[string][]types.ContentChunk)
-		for _, chunk := range allChunks {
-			chunksByDatasource[chunk.DatasourceID] = append(chunksByDatasource[chunk.DatasourceID], chunk)
-		}
+	// Group chunks by datasourceID for parallel processing
+	chunksByDatasource := make(map[string][]types.ContentChunk)
+	for _, chunk := range allChunks {
+		chunksByDatasource[chunk.DatasourceID] = append(chunksByDatasource[chunk.DatasourceID], chunk)
+	}
 
-		// Enqueue separate jobs for each datasource to enable parallel processing
-		enqueuedJobs := 0
-		droppedJobs := 0
+	// Encode one embedding job per datasource so they are enqueued in the same
+	// transaction that records the sources
+	var work []loaders.NewWork
+	if s.workers != nil {
 		for datasourceID, chunks := range chunksByDatasource {
-			embJob := EmbeddingJob{
-				JobID:     fmt.Sprintf("%s-ds-%s", jobID, datasourceID),
-				UserID:    req.UserID,
-				ChatbotID: req.ChatbotID,
-				Chunks:    chunks,
-				CreatedAt: time.Now().UTC(),
-			}
-			if ok := s.workers.Enqueue(embJob); !ok {
-				utils.Zlog.Warn("Embedding queue is full; dropping job",
-					zap.String("jobId", embJob.JobID),
+			item, err := s.workers.EmbeddingWork(EmbeddingJob{
+				JobID:          fmt.Sprintf("%s-ds-%s", jobID, datasourceID),
+				IngestionJobID: jobID,
+				DatasourceID:   datasourceID,
+				UserID:         req.UserID,
+				ChatbotID:      req.ChatbotID,
+				Chunks:         chunks,
+				CreatedAt:      time.Now().UTC(),
+				Replace:        replaceMode(req),
+			})
+			if err != nil {
+				utils.Zlog.Warn("Failed to encode embedding job; dropping job",
+					zap.String("jobId", jobID),
 					zap.String("datasourceId", datasourceID),
 					zap.Int("chunks", len(chunks)))
-				droppedJobs++
-			} else {
-				enqueuedJobs++
+				delete(chunksByDatasource, datasourceID)
+				for i := range results {
+					if results[i].DatasourceID == datasourceID {
+						results[i].Status = types.SourceStatusFailed
+						results[i].Error = "failed to enqueue embedding job"
+					}
+				}
+				continue
 			}
+			work = append(work, item)
 		}
+	}
+
+	// Sources with chunks wait on the embedding workers; the rest are already final
+	for i := range results {
+		if results[i].Status != types.SourceStatusSuccess {
+			continue
+		}
+		if _, ok := chunksByDatasource[results[i].DatasourceID]; ok {
+			results[i].Status = types.SourceStatusEmbedding
+		} else {
+			results[i].Status = types.SourceStatusCompleted
+		}
+	}
 
+	err = s.db.RecordIngestionJobSources(ctx, jobID, results, totalChunks, len(chunksByDatasource), work)
+	switch {
+	case errors.Is(err, loaders.ErrJobCancelled):
+		utils.Zlog.Info("Ingestion job cancelled, not enqueueing embeddings", zap.String("jobId", jobID))
+		return
+	case errors.Is(err, loaders.ErrSourcesRecorded):
+		utils.Zlog.Info("Ingestion job sources recorded by an earlier delivery, not enqueueing embeddings",
+			zap.String("jobId", jobID))
+		return
+	case err != nil:
+		utils.Zlog.Error("Failed to record ingestion job sources",
+			zap.String("jobId", jobID),
+			zap.Error(err))
+		if err := s.db.UpdateIngestionJobStatus(ctx, jobID, types.StatusFailed, "failed to record sources"); err != nil {
+			utils.Zlog.Error("Failed to mark ingestion job as failed",
+				zap.String("jobId", jobID),
+				zap.Error(err))
+		}
+		s.dispatcher.NotifyJob(ctx, jobID)
+		return
+	}
+
+	for _, result := range results {
+		if result.Status == types.SourceStatusCompleted || result.Status == types.SourceStatusFailed {
+			s.dispatcher.NotifyDatasource(ctx, jobID, result.DatasourceID)
+		}
+	}
+	if len(chunksByDatasource) == 0 {
+		s.dispatcher.NotifyJob(ctx, jobID)
+	}
+	if len(work) > 0 {
+		s.workers.Wake()
 		utils.Zlog.Info("Embedding jobs enqueued",
 			zap.String("jobId", jobID),
-			zap.Int("datasources", len(chunksByDatasource)),
-			zap.Int("enqueuedJobs", enqueuedJobs),
-			zap.Int("droppedJobs", droppedJobs),
+			zap.Int("datasources", len(work)),
 			zap.Int("totalChunks", len(allChunks)))
 	}
 
@@ -143,19 +440,7 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 	var mu sync.Mutex
 
 	// Create processor factory with configuration
-	config := &types.Config{
-		ChunkSize:    1000,
-		ChunkOverlap: 200,
-	}
-	if req.Options != nil {
-		if req.Options.ChunkSize > 0 {
-			config.ChunkSize = req.Options.ChunkSize
-		}
-		if req.Options.ChunkOverlap > 0 {
-			config.ChunkOverlap = req.Options.ChunkOverlap
-		}
-	}
-	factory := processors.NewFactory(config)
+	factory := processors.NewFactory(chunkingConfig(req))
 
 	// Initialize file downloader
 	downloader := utils.NewFileDownloader()
@@ -166,7 +451,9 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 		wg.Add(1)
 		go func(websiteURL types.WebsiteURL) {
 			defer wg.Done()
-			result, content := s.processSource(ctx, factory.CreateWebsiteProcessor(websiteURL.URL), req.ChatbotID, req.UserID, websiteURL.URL, websiteURL.DatasourceID)
+			s.sourceStarted(ctx, jobID, websiteURL.DatasourceID, websiteURL.URL)
+			result, content := s.processSource(ctx, jobID, factory.CreateWebsiteProcessor(websiteURL.URL), req.ChatbotID, req.UserID, websiteURL.URL, websiteURL.DatasourceID)
+			s.sourceProcessed(ctx, jobID, result)
 			mu.Lock()
 			results = append(results, result)
 			if content != nil {
@@ -181,7 +468,9 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 		wg.Add(1)
 		go func(qa types.QAPair) {
 			defer wg.Done()
-			result, content := s.processSource(ctx, factory.CreateQAProcessor(qa), req.ChatbotID, req.UserID, qa.Question, qa.DatasourceID)
+			s.sourceStarted(ctx, jobID, qa.DatasourceID, qa.Question)
+			result, content := s.processSource(ctx, jobID, factory.CreateQAProcessor(qa), req.ChatbotID, req.UserID, qa.Question, qa.DatasourceID)
+			s.sourceProcessed(ctx, jobID, result)
 			mu.Lock()
 			results = append(results, result)
 			if content != nil {
@@ -197,6 +486,7 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 		wg.Add(1)
 		go func(doc types.DocumentMetadata) {
 			defer wg.Done()
+			s.sourceStarted(ctx, jobID, doc.DatasourceID, doc.Pathname)
 
 			// Download the file
 			utils.Zlog.Info("Downloading document",
@@ -213,7 +503,7 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 
 				// Mark datasource as FAILED in database
 				if s.db != nil && doc.DatasourceID != "" {
-					if dbErr := s.db.UpdateDataSourceStatus(ctx, []string{doc.DatasourceID}, "FAILED"); dbErr != nil {
+					if dbErr := s.db.UpdateJobDataSourceStatus(ctx, jobID, []string{doc.DatasourceID}, "FAILED"); dbErr != nil {
 						utils.Zlog.Error("Failed to update datasource status to FAILED",
 							zap.String("datasourceId", doc.DatasourceID),
 							zap.Error(dbErr))
@@ -223,16 +513,18 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 					}
 				}
 
-				mu.Lock()
-				results = append(results, types.SourceResult{
+				result := types.SourceResult{
 					DatasourceID: doc.DatasourceID,
 					SourceType:   types.DetermineSourceTypeFromContentType(doc.ContentType),
 					Source:       doc.Pathname,
-					Status:       "failed",
+					Status:       types.SourceStatusFailed,
 					Error:        fmt.Sprintf("Failed to download: %v", err),
 					ChunkCount:   0,
 					ProcessedAt:  time.Now().UTC(),
-				})
+				}
+				s.sourceProcessed(ctx, jobID, result)
+				mu.Lock()
+				results = append(results, result)
 				mu.Unlock()
 				return
 			}
@@ -244,7 +536,8 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 				doc.ContentType,
 			)
 
-			result, content := s.processSource(ctx, processor, req.ChatbotID, req.UserID, doc.Pathname, doc.DatasourceID)
+			result, content := s.processSource(ctx, jobID, processor, req.ChatbotID, req.UserID, doc.Pathname, doc.DatasourceID)
+			s.sourceProcessed(ctx, jobID, result)
 			mu.Lock()
 			results = append(results, result)
 			if content != nil {
@@ -255,12 +548,70 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 		}(doc)
 	}
 
+	// Process uploaded files from the spool
+	for _, upload := range req.Uploads {
+		wg.Add(1)
+		go func(upload types.UploadedDocument) {
+			defer wg.Done()
+			s.sourceStarted(ctx, jobID, upload.DatasourceID, upload.Filename)
+
+			var content []byte
+			err := errors.New("upload spool not configured")
+			if s.spool != nil {
+				content, err = s.spool.Read(upload.SpoolFile)
+			}
+			if err != nil {
+				utils.Zlog.Error("Failed to read uploaded document",
+					zap.String("filename", upload.Filename),
+					zap.String("datasourceId", upload.DatasourceID),
+					zap.Error(err))
+
+				// Mark datasource as FAILED in database
+				if s.db != nil && upload.DatasourceID != "" {
+					if dbErr := s.db.UpdateJobDataSourceStatus(ctx, jobID, []string{upload.DatasourceID}, "FAILED"); dbErr != nil {
+						utils.Zlog.Error("Failed to update datasource status to FAILED",
+							zap.String("datasourceId", upload.DatasourceID),
+							zap.Error(dbErr))
+					}
+				}
+
+				result := types.SourceResult{
+					DatasourceID: upload.DatasourceID,
+					SourceType:   types.DetermineSourceTypeFromContentType(upload.ContentType),
+					Source:       upload.Filename,
+					Status:       types.SourceStatusFailed,
+					Error:        fmt.Sprintf("Failed to read upload: %v", err),
+					ChunkCount:   0,
+					ProcessedAt:  time.Now().UTC(),
+				}
+				s.sourceProcessed(ctx, jobID, result)
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+				return
+			}
+
+			processor := factory.CreateDocumentProcessorFromBytes(content, upload.Filename, upload.ContentType)
+			result, processed := s.processSource(ctx, jobID, processor, req.ChatbotID, req.UserID, upload.Filename, upload.DatasourceID)
+			s.sourceProcessed(ctx, jobID, result)
+			mu.Lock()
+			results = append(results, result)
+			if processed != nil {
+				totalChunks += len(processed.Chunks)
+				allChunks = append(allChunks, s.convertAndAddCitationToChunks(processed, upload.DatasourceID)...)
+			}
+			mu.Unlock()
+		}(upload)
+	}
+
 	for i, textContent := range req.TextContent {
 		wg.Add(1)
 		go func(textContent types.TextContent, index int) {
 			defer wg.Done()
 			topic := fmt.Sprintf("Text content #%d", index+1)
-			result, content := s.processSource(ctx, factory.CreateTextProcessor(textContent.Content, topic), req.ChatbotID, req.UserID, topic, textContent.DatasourceID)
+			s.sourceStarted(ctx, jobID, textContent.DatasourceID, topic)
+			result, content := s.processSource(ctx, jobID, factory.CreateTextProcessor(textContent.Content, topic), req.ChatbotID, req.UserID, topic, textContent.DatasourceID)
+			s.sourceProcessed(ctx, jobID, result)
 			mu.Lock()
 			results = append(results, result)
 			if content != nil {
@@ -276,7 +627,7 @@ func (s *Service) processAllSources(ctx context.Context, req types.ProcessReques
 	return results, totalChunks, allChunks
 }
 
-func (s *Service) processSource(ctx context.Context, processor types.Processor, chatbotID, userID, source string, datasourceID string) (types.SourceResult, *types.ProcessedContent) {
+func (s *Service) processSource(ctx context.Context, jobID string, processor types.Processor, chatbotID, userID, source string, datasourceID string) (types.SourceResult, *types.ProcessedContent) {
 	startTime := time.Now()
 
 	utils.Zlog.Info("Processing source",
@@ -293,7 +644,7 @@ func (s *Service) processSource(ctx context.Context, processor types.Processor,
 
 		// Mark datasource as FAILED in database
 		if s.db != nil && datasourceID != "" {
-			if dbErr := s.db.UpdateDataSourceStatus(ctx, []string{datasourceID}, "FAILED"); dbErr != nil {
+			if dbErr := s.db.UpdateJobDataSourceStatus(ctx, jobID, []string{datasourceID}, "FAILED"); dbErr != nil {
 				utils.Zlog.Error("Failed to update datasource status to FAILED",
 					zap.String("datasourceId", datasourceID),
 					zap.Error(dbErr))
@@ -307,7 +658,7 @@ func (s *Service) processSource(ctx context.Context, processor types.Processor,
 			DatasourceID: datasourceID,
 			SourceType:   processor.GetSourceType(),
 			Source:       source,
-			Status:       "failed",
+			Status:       types.SourceStatusFailed,
 			Error:        err.Error(),
 			ChunkCount:   0,
 			ProcessedAt:  time.Now().UTC(),
@@ -324,7 +675,7 @@ func (s *Service) processSource(ctx context.Context, processor types.Processor,
 		DatasourceID: datasourceID,
 		SourceType:   processor.GetSourceType(),
 		Source:       source,
-		Status:       "success",
+		Status:       types.SourceStatusSuccess,
 		Message:      fmt.Sprintf("Processed successfully in %v", duration),
 		ChunkCount:   len(content.Chunks),
 		ProcessedAt:  time.Now().UTC(),
@@ -341,8 +692,67 @@ func (s *Service) storeProcessedContent(ctx context.Context, chatbotID, userID s
 	return nil
 }
 
+func collectDatasourceIDs(req types.ProcessRequest) []string {
+	var ids []string
+	for _, w := range req.WebsiteURLs {
+		ids = append(ids, w.DatasourceID)
+	}
+	for _, qa := range req.QandAData {
+		ids = append(ids, qa.DatasourceID)
+	}
+	for _, doc := range req.Documents {
+		ids = append(ids, doc.DatasourceID)
+	}
+	for _, text := range req.TextContent {
+		ids = append(ids, text.DatasourceID)
+	}
+	for _, upload := range req.Uploads {
+		ids = append(ids, upload.DatasourceID)
+	}
+	return ids
+}
+
+// writeMode returns how the request's embeddings are written, append by default
+func writeMode(req types.ProcessRequest) string {
+	if req.Options != nil && req.Options.Mode != "" {
+		return req.Options.Mode
+	}
+	return types.WriteModeAppend
+}
+
+func replaceMode(req types.ProcessRequest) bool {
+	return writeMode(req) == types.WriteModeReplace
+}
+
+// chunkingConfig returns the processor configuration for the request's options
+func chunkingConfig(req types.ProcessRequest) *types.Config {
+	config := types.DefaultConfig()
+	if req.Options != nil && req.Options.ChunkUnit == types.ChunkUnitTokens {
+		config = types.DefaultTokenConfig()
+	}
+	if req.Options != nil {
+		if req.Options.ChunkSize > 0 {
+			config.ChunkSize = req.Options.ChunkSize
+		}
+		if req.Options.ChunkOverlap > 0 {
+			config.ChunkOverlap = req.Options.ChunkOverlap
+		}
+	}
+	return config
+}
+
+// removeUploads deletes the request's files from the upload spool
+func (s *Service) removeUploads(req types.ProcessRequest) {
+	if s.spool == nil {
+		return
+	}
+	for _, upload := range req.Uploads {
+		s.spool.Remove(upload.SpoolFile)
+	}
+}
+
 func (s *Service) calculateTotalSources(req types.ProcessRequest) int {
-	return len(req.WebsiteURLs) + len(req.QandAData) + len(req.Documents) + len(req.TextContent)
+	return len(req.WebsiteURLs) + len(req.QandAData) + len(req.Documents) + len(req.TextContent) + len(req.Uploads)
 }
 
 func (s *Service) generateResponseMessage(successful, failed int) string {
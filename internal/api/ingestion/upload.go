@@ -0,0 +1,177 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	maxUploadMetadataBytes = 1 << 20
+	uploadReadTimeout      = 10 * time.Minute
+	uploadWriteTimeout     = 30 * time.Second
+)
+
+// ProcessUpload godoc
+// @Summary Process uploaded files
+// @Description Multipart variant of /process. The first part, "metadata", is a ProcessRequest with a "files" array; it is followed by one "file" part per entry, matched by position.
+// @Tags ingestion
+// @Accept multipart/form-data
+// @Produce json
+// @Param metadata formData string true "types.UploadRequest as JSON"
+// @Param file formData file true "Files in the order of metadata.files"
+// @Param Idempotency-Key header string false "Return the original response for duplicate submissions"
+// @Success 200 {object} ProcessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/process/upload [post]
+func (ctrl *Controller) ProcessUpload(c *gin.Context) {
+	spool := ctrl.service.Spool()
+	if spool == nil {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error:     "Service Unavailable",
+			Message:   "File uploads are not available: the upload spool could not be initialized",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	// Large files take longer than the server's timeouts allow regular requests.
+	// The write deadline runs from the start of the request too, so it has to
+	// cover the upload or the response is lost and the client retries.
+	rc := http.NewResponseController(c.Writer)
+	deadline := time.Now().Add(uploadReadTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		utils.Zlog.Warn("Failed to extend read deadline for upload", zap.Error(err))
+	}
+	if err := rc.SetWriteDeadline(deadline.Add(uploadWriteTimeout)); err != nil {
+		utils.Zlog.Warn("Failed to extend write deadline for upload", zap.Error(err))
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		uploadError(c, http.StatusBadRequest, "Bad Request", "request must be multipart/form-data")
+		return
+	}
+
+	part, err := reader.NextPart()
+	if err != nil || part.FormName() != "metadata" {
+		uploadError(c, http.StatusBadRequest, "Bad Request", `the first part must be "metadata"`)
+		return
+	}
+
+	var meta types.UploadRequest
+	if err := json.NewDecoder(io.LimitReader(part, maxUploadMetadataBytes)).Decode(&meta); err != nil {
+		uploadError(c, http.StatusBadRequest, "Bad Request", fmt.Sprintf("invalid metadata: %v", err))
+		return
+	}
+	if len(meta.Uploads) > 0 {
+		uploadError(c, http.StatusBadRequest, "Bad Request", "metadata cannot set uploads, describe the files in files")
+		return
+	}
+	if !ctrl.prepare(c, &meta.ProcessRequest) {
+		return
+	}
+	if err := validate.Struct(&meta); err != nil {
+		uploadError(c, http.StatusBadRequest, "Bad Request", fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	uploads, status, err := spoolUploads(reader, spool, meta.Files)
+	if err != nil {
+		for _, upload := range uploads {
+			spool.Remove(upload.SpoolFile)
+		}
+		utils.Zlog.Error("Failed to receive uploaded files", zap.Error(err))
+		uploadError(c, status, http.StatusText(status), err.Error())
+		return
+	}
+
+	req := meta.ProcessRequest
+	req.Uploads = uploads
+	if err := validateChunkUnit(&req); err != nil {
+		for _, upload := range uploads {
+			spool.Remove(upload.SpoolFile)
+		}
+		uploadError(c, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	response, err := ctrl.service.Process(c.Request.Context(), req)
+	ctrl.respond(c, response, err)
+}
+
+// spoolUploads streams the remaining "file" parts into the spool. On error it
+// returns the files spooled so far with the HTTP status to report.
+func spoolUploads(reader *multipart.Reader, spool *utils.FileSpool, files []types.UploadFileMetadata) ([]types.UploadedDocument, int, error) {
+	var uploads []types.UploadedDocument
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return uploads, http.StatusBadRequest, fmt.Errorf("failed to read multipart body: %w", err)
+		}
+		if part.FormName() != "file" {
+			return uploads, http.StatusBadRequest, fmt.Errorf(`unexpected part %q, only "file" parts may follow metadata`, part.FormName())
+		}
+		if len(uploads) == len(files) {
+			return uploads, http.StatusBadRequest, errors.New("more file parts than entries in metadata.files")
+		}
+		if part.FileName() == "" {
+			return uploads, http.StatusBadRequest, errors.New("file parts must have a filename")
+		}
+
+		file := files[len(uploads)]
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = part.Header.Get("Content-Type")
+		}
+		if contentType == "application/octet-stream" {
+			// Let the processor factory go by the file extension
+			contentType = ""
+		}
+
+		name, size, err := spool.Write(part)
+		if errors.Is(err, utils.ErrFileTooLarge) {
+			return uploads, http.StatusRequestEntityTooLarge, fmt.Errorf("%s: %w", part.FileName(), err)
+		}
+		if err != nil {
+			return uploads, http.StatusInternalServerError, err
+		}
+
+		uploads = append(uploads, types.UploadedDocument{
+			DatasourceID: file.DatasourceID,
+			Filename:     part.FileName(),
+			ContentType:  contentType,
+			SpoolFile:    name,
+			Size:         size,
+		})
+	}
+
+	if len(uploads) != len(files) {
+		return uploads, http.StatusBadRequest, fmt.Errorf("expected %d file parts, got %d", len(files), len(uploads))
+	}
+	return uploads, http.StatusOK, nil
+}
+
+func uploadError(c *gin.Context, status int, title, message string) {
+	c.JSON(status, types.ErrorResponse{
+		Error:     title,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	})
+}
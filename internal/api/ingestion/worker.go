@@ -2,6 +2,11 @@ package ingestion
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
 	"sync"
 	"time"
 
@@ -9,51 +14,119 @@ import (
 	"github.com/Conversly/db-ingestor/internal/loaders"
 	"github.com/Conversly/db-ingestor/internal/types"
 	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 type EmbeddingJob struct {
-	JobID      string
-	UserID     string
-	ChatbotID  string
-	Chunks     []types.ContentChunk
-	CreatedAt  time.Time
-	RetryCount int // Track retry attempts to prevent infinite loops
+	JobID          string               `json:"jobId"`
+	IngestionJobID string               `json:"ingestionJobId"` // ingestion job that produced the chunks
+	DatasourceID   string               `json:"datasourceId"`
+	UserID         string               `json:"userId"`
+	ChatbotID      string               `json:"chatbotId"`
+	Chunks         []types.ContentChunk `json:"chunks"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	RetryCount     int                  `json:"retryCount"`               // Track retry attempts to prevent infinite loops
+	Replace        bool                 `json:"replace,omitempty"`        // swap out the datasource's previous embeddings once all chunks are persisted
+	EmbeddingModel string               `json:"embeddingModel,omitempty"` // ModelID of the embedder that produced the chunk embeddings
 }
 
 const maxEmbeddingRetries = 3
 
+// embedBatchSize is the number of chunks handed to the embedder at a time;
+// progress is reported and cancellation checked between batches
+const embedBatchSize = 100
+
+// maxReportedRejections caps the rejected chunks listed in a persisted event
+const maxReportedRejections = 10
+
+// errLeaseLost is the cancellation cause of an item another worker re-claimed
+var errLeaseLost = errors.New("work lease lost to another worker")
+
+// uploadRetention bounds how long an uploaded file no queued job refers to
+// stays in the spool
+const uploadRetention = 24 * time.Hour
+
 type IngestionJob struct {
-	JobID   string
-	Request types.ProcessRequest
+	JobID   string               `json:"jobId"`
+	Request types.ProcessRequest `json:"request"`
+}
+
+// Kinds of items stored in the work queue
+const (
+	queueKindIngestion = "ingestion"
+	queueKindEmbedding = "embedding"
+)
+
+// QueueOptions controls how workers poll and lease items from the work queue
+type QueueOptions struct {
+	PollInterval  time.Duration
+	LeaseDuration time.Duration
+	MaxAttempts   int
+
+	// How long progress events and dead items are kept; zero keeps them for
+	// defaultRetention
+	EventRetention time.Duration
+	DeadRetention  time.Duration
 }
 
+// defaultRetention applies to progress events and dead items unless configured
+const defaultRetention = 7 * 24 * time.Hour
+
+// pruneInterval is how often the workers delete expired events and dead items
+const pruneInterval = time.Hour
+
+// WorkerPool runs ingestion and embedding jobs from the Postgres-backed work
+// queue, so queued jobs survive restarts and are shared between replicas.
 type WorkerPool struct {
-	embeddingJobs chan EmbeddingJob
-	ingestionJobs chan IngestionJob
-	quit          chan struct{}
-	started       bool
-	wg            sync.WaitGroup
-	numWorkers    int
-	embedder      *embedder.GeminiEmbedder
-	db            *loaders.PostgresClient
-	processFunc   func(ctx context.Context, job IngestionJob)
-}
-
-func NewWorkerPool(numWorkers int, queueCapacity int, geminiEmbedder *embedder.GeminiEmbedder, db *loaders.PostgresClient) *WorkerPool {
+	quit        chan struct{}
+	wakeup      chan struct{}
+	started     bool
+	wg          sync.WaitGroup
+	numWorkers  int
+	owner       string
+	options     QueueOptions
+	runningMu   sync.Mutex
+	running     map[string]map[int64]context.CancelFunc // ingestion job ID -> item ID -> cancel
+	embedder    embedder.Embedder
+	cache       *embedder.Cache
+	db          *loaders.PostgresClient
+	dispatcher  *webhooks.Dispatcher
+	spool       *utils.FileSpool
+	processFunc func(ctx context.Context, job IngestionJob)
+}
+
+func NewWorkerPool(numWorkers int, options QueueOptions, textEmbedder embedder.Embedder, db *loaders.PostgresClient) *WorkerPool {
 	if numWorkers <= 0 {
 		numWorkers = 1
 	}
-	if queueCapacity <= 0 {
-		queueCapacity = 100
+	if options.PollInterval <= 0 {
+		options.PollInterval = 2 * time.Second
+	}
+	if options.LeaseDuration <= 0 {
+		options.LeaseDuration = 2 * time.Minute
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.EventRetention <= 0 {
+		options.EventRetention = defaultRetention
+	}
+	if options.DeadRetention <= 0 {
+		options.DeadRetention = defaultRetention
 	}
+
+	hostname, _ := os.Hostname()
 	return &WorkerPool{
-		embeddingJobs: make(chan EmbeddingJob, queueCapacity),
-		ingestionJobs: make(chan IngestionJob, queueCapacity),
-		quit:          make(chan struct{}),
-		numWorkers:    numWorkers,
-		embedder:      geminiEmbedder,
-		db:            db,
+		quit:       make(chan struct{}),
+		wakeup:     make(chan struct{}, 1),
+		numWorkers: numWorkers,
+		owner:      fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8]),
+		options:    options,
+		running:    make(map[string]map[int64]context.CancelFunc),
+		embedder:   textEmbedder,
+		db:         db,
 	}
 }
 
@@ -61,6 +134,21 @@ func (wp *WorkerPool) SetProcessFunc(fn func(ctx context.Context, job IngestionJ
 	wp.processFunc = fn
 }
 
+// SetDispatcher enables webhook callbacks for outcomes recorded by the workers
+func (wp *WorkerPool) SetDispatcher(dispatcher *webhooks.Dispatcher) {
+	wp.dispatcher = dispatcher
+}
+
+// SetEmbeddingCache makes workers reuse vectors of previously embedded text
+func (wp *WorkerPool) SetEmbeddingCache(cache *embedder.Cache) {
+	wp.cache = cache
+}
+
+// SetUploadSpool lets the workers clean up uploads whose jobs never ran
+func (wp *WorkerPool) SetUploadSpool(spool *utils.FileSpool) {
+	wp.spool = spool
+}
+
 func (wp *WorkerPool) Start() {
 	if wp.started {
 		return
@@ -70,23 +158,52 @@ func (wp *WorkerPool) Start() {
 		wp.wg.Add(1)
 		go func(workerID int) {
 			defer wp.wg.Done()
-			utils.Zlog.Info("Worker started", zap.Int("workerId", workerID))
+			utils.Zlog.Info("Worker started", zap.Int("workerId", workerID), zap.String("owner", wp.owner))
 			for {
 				select {
 				case <-wp.quit:
 					utils.Zlog.Info("Worker stopping", zap.Int("workerId", workerID))
 					return
-				case job := <-wp.ingestionJobs:
-					if wp.processFunc != nil {
-						ctx := context.Background()
-						wp.processFunc(ctx, job)
-					}
-				case job := <-wp.embeddingJobs:
-					wp.processEmbeddingJob(workerID, job)
+				default:
+				}
+
+				if wp.claimAndRun(workerID) {
+					continue
+				}
+
+				select {
+				case <-wp.quit:
+					utils.Zlog.Info("Worker stopping", zap.Int("workerId", workerID))
+					return
+				case <-wp.wakeup:
+				case <-time.After(wp.options.PollInterval):
 				}
 			}
 		}(i + 1)
 	}
+
+	wp.wg.Add(1)
+	go func() {
+		defer wp.wg.Done()
+		ticker := time.NewTicker(wp.options.LeaseDuration)
+		defer ticker.Stop()
+		var lastPrune time.Time
+		for {
+			select {
+			case <-wp.quit:
+				return
+			case <-ticker.C:
+				wp.deadLetterExpired()
+				if wp.spool != nil {
+					wp.sweepUploads()
+				}
+				if time.Since(lastPrune) >= pruneInterval {
+					wp.prune()
+					lastPrune = time.Now()
+				}
+			}
+		}
+	}()
 }
 
 func (wp *WorkerPool) Stop(ctx context.Context) {
@@ -108,34 +225,310 @@ func (wp *WorkerPool) Stop(ctx context.Context) {
 }
 
 func (wp *WorkerPool) Enqueue(job EmbeddingJob) bool {
-	select {
-	case <-wp.quit:
+	return wp.enqueue(queueKindEmbedding, job.JobID, job.IngestionJobID, job)
+}
+
+func (wp *WorkerPool) EnqueueIngestion(job IngestionJob) bool {
+	return wp.enqueue(queueKindIngestion, job.JobID, job.JobID, job)
+}
+
+// enqueue inserts work into the durable queue. It does so while the pool is
+// stopping too: retries written during shutdown are picked up by whichever
+// worker runs next instead of failing their datasources.
+func (wp *WorkerPool) enqueue(kind, jobID, ingestionJobID string, job interface{}) bool {
+	item, err := wp.newWork(kind, jobID, job)
+	if err != nil {
 		return false
-	default:
 	}
-	select {
-	case wp.embeddingJobs <- job:
-		return true
-	default:
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := wp.db.EnqueueWork(ctx, item.Kind, item.JobID, ingestionJobID, item.Payload, item.MaxAttempts); err != nil {
+		utils.Zlog.Error("Failed to enqueue work",
+			zap.String("kind", kind),
+			zap.String("jobId", jobID),
+			zap.Error(err))
 		return false
 	}
+
+	wp.Wake()
+	return true
 }
 
-func (wp *WorkerPool) EnqueueIngestion(job IngestionJob) bool {
+// EmbeddingWork encodes job as a queue item for callers that enqueue it in
+// their own transaction; call Wake once it is committed
+func (wp *WorkerPool) EmbeddingWork(job EmbeddingJob) (loaders.NewWork, error) {
+	return wp.newWork(queueKindEmbedding, job.JobID, job)
+}
+
+func (wp *WorkerPool) newWork(kind, jobID string, job interface{}) (loaders.NewWork, error) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		utils.Zlog.Error("Failed to encode queue payload",
+			zap.String("kind", kind),
+			zap.String("jobId", jobID),
+			zap.Error(err))
+		return loaders.NewWork{}, err
+	}
+	return loaders.NewWork{Kind: kind, JobID: jobID, Payload: payload, MaxAttempts: wp.options.MaxAttempts}, nil
+}
+
+// Wake nudges an idle local worker instead of waiting for the next poll
+func (wp *WorkerPool) Wake() {
 	select {
 	case <-wp.quit:
-		return false
+		return
 	default:
 	}
 	select {
-	case wp.ingestionJobs <- job:
-		return true
+	case wp.wakeup <- struct{}{}:
 	default:
+	}
+}
+
+// claimAndRun leases one item from the queue and runs it to completion while
+// heartbeating its lease. Returns false when nothing was claimed.
+func (wp *WorkerPool) claimAndRun(workerID int) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	item, err := wp.db.ClaimWork(ctx, wp.owner, wp.options.LeaseDuration)
+	cancel()
+	if err != nil {
+		utils.Zlog.Error("Failed to claim work", zap.Int("workerId", workerID), zap.Error(err))
 		return false
 	}
+	if item == nil {
+		return false
+	}
+
+	utils.Zlog.Info("Claimed work item",
+		zap.Int("workerId", workerID),
+		zap.Int64("itemId", item.ID),
+		zap.String("kind", item.Kind),
+		zap.String("jobId", item.JobID),
+		zap.Int("attempt", item.Attempts))
+
+	var runErr error
+	if item.Cancelled {
+		utils.Zlog.Info("Skipping work item of cancelled job",
+			zap.Int("workerId", workerID),
+			zap.Int64("itemId", item.ID),
+			zap.String("ingestionJobId", item.IngestionJobID))
+	} else {
+		jobCtx, cancelJob := context.WithCancelCause(context.Background())
+		wp.track(item, func() { cancelJob(context.Canceled) })
+		stopHeartbeat := wp.heartbeat(workerID, item.ID, cancelJob)
+		runErr = wp.runItem(jobCtx, workerID, item)
+		stopHeartbeat()
+		wp.untrack(item)
+		cancelJob(context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if runErr != nil {
+		utils.Zlog.Error("Work item cannot be processed",
+			zap.Int("workerId", workerID),
+			zap.Int64("itemId", item.ID),
+			zap.Error(runErr))
+		if err := wp.db.FailWork(ctx, item.ID, wp.owner, runErr.Error()); err != nil {
+			utils.Zlog.Error("Failed to dead-letter work item", zap.Int64("itemId", item.ID), zap.Error(err))
+		}
+		return true
+	}
+	if err := wp.db.CompleteWork(ctx, item.ID, wp.owner); err != nil {
+		utils.Zlog.Error("Failed to complete work item", zap.Int64("itemId", item.ID), zap.Error(err))
+	}
+	return true
+}
+
+func (wp *WorkerPool) runItem(ctx context.Context, workerID int, item *loaders.WorkItem) error {
+	switch item.Kind {
+	case queueKindIngestion:
+		var job IngestionJob
+		if err := json.Unmarshal(item.Payload, &job); err != nil {
+			return fmt.Errorf("failed to decode ingestion job: %w", err)
+		}
+		if wp.processFunc != nil {
+			wp.processFunc(ctx, job)
+		}
+	case queueKindEmbedding:
+		var job EmbeddingJob
+		if err := json.Unmarshal(item.Payload, &job); err != nil {
+			return fmt.Errorf("failed to decode embedding job: %w", err)
+		}
+		wp.processEmbeddingJob(ctx, workerID, job)
+	default:
+		return fmt.Errorf("unknown work item kind %q", item.Kind)
+	}
+	return nil
+}
+
+// heartbeat keeps extending the lease of a running item until the returned stop
+// function is called. It cancels the item when its ingestion job is cancelled
+// from another process or when the lease was lost to another worker.
+func (wp *WorkerPool) heartbeat(workerID int, itemID int64, cancelJob context.CancelCauseFunc) func() {
+	interval := wp.options.LeaseDuration / 3
+	if interval > 5*time.Second {
+		interval = 5 * time.Second
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				owned, cancelled, err := wp.db.ExtendWorkLease(ctx, itemID, wp.owner, wp.options.LeaseDuration)
+				cancel()
+				if err != nil {
+					utils.Zlog.Error("Failed to extend work lease",
+						zap.Int("workerId", workerID),
+						zap.Int64("itemId", itemID),
+						zap.Error(err))
+				} else if !owned {
+					// Another worker has re-claimed the item; stop rather than run it twice
+					utils.Zlog.Warn("Lost lease on work item, stopping it",
+						zap.Int("workerId", workerID),
+						zap.Int64("itemId", itemID))
+					cancelJob(errLeaseLost)
+					return
+				} else if cancelled {
+					utils.Zlog.Info("Ingestion job cancelled, stopping work item",
+						zap.Int("workerId", workerID),
+						zap.Int64("itemId", itemID))
+					cancelJob(context.Canceled)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// track registers the cancel function of a running item under its ingestion job
+func (wp *WorkerPool) track(item *loaders.WorkItem, cancel context.CancelFunc) {
+	if item.IngestionJobID == "" {
+		return
+	}
+	wp.runningMu.Lock()
+	defer wp.runningMu.Unlock()
+	if wp.running[item.IngestionJobID] == nil {
+		wp.running[item.IngestionJobID] = make(map[int64]context.CancelFunc)
+	}
+	wp.running[item.IngestionJobID][item.ID] = cancel
+}
+
+func (wp *WorkerPool) untrack(item *loaders.WorkItem) {
+	wp.runningMu.Lock()
+	defer wp.runningMu.Unlock()
+	delete(wp.running[item.IngestionJobID], item.ID)
+	if len(wp.running[item.IngestionJobID]) == 0 {
+		delete(wp.running, item.IngestionJobID)
+	}
+}
+
+// CancelJob cancels the items of an ingestion job running in this process.
+// Workers in other processes notice the cancellation on their next heartbeat.
+func (wp *WorkerPool) CancelJob(ingestionJobID string) int {
+	wp.runningMu.Lock()
+	defer wp.runningMu.Unlock()
+	for _, cancel := range wp.running[ingestionJobID] {
+		cancel()
+	}
+	return len(wp.running[ingestionJobID])
+}
+
+// sweepUploads deletes stale spool files, keeping those of ingestion items that
+// are still queued or running, however long they have waited
+func (wp *WorkerPool) sweepUploads() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	files, err := wp.db.QueuedUploadFiles(ctx, queueKindIngestion)
+	if err != nil {
+		utils.Zlog.Error("Failed to list queued uploads, skipping spool sweep", zap.Error(err))
+		return
+	}
+	inUse := make(map[string]bool, len(files))
+	for _, file := range files {
+		inUse[file] = true
+	}
+	wp.spool.Sweep(uploadRetention, inUse)
+}
+
+// prune deletes progress events and dead items past their retention
+func (wp *WorkerPool) prune() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	events, err := wp.db.PruneIngestionJobEvents(ctx, wp.options.EventRetention)
+	if err != nil {
+		utils.Zlog.Error("Failed to prune ingestion job events", zap.Error(err))
+	}
+	dead, err := wp.db.PruneDeadWork(ctx, wp.options.DeadRetention)
+	if err != nil {
+		utils.Zlog.Error("Failed to prune dead work items", zap.Error(err))
+	}
+	if events > 0 || dead > 0 {
+		utils.Zlog.Info("Pruned expired events and dead work items",
+			zap.Int64("events", events),
+			zap.Int64("deadItems", dead))
+	}
+}
+
+// deadLetterExpired fails items whose worker died on their last attempt
+func (wp *WorkerPool) deadLetterExpired() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	items, err := wp.db.DeadLetterExpiredWork(ctx)
+	if err != nil {
+		utils.Zlog.Error("Failed to dead-letter expired work", zap.Error(err))
+		return
+	}
+
+	for _, item := range items {
+		utils.Zlog.Error("Work item exhausted its attempts",
+			zap.Int64("itemId", item.ID),
+			zap.String("kind", item.Kind),
+			zap.String("jobId", item.JobID),
+			zap.Int("attempts", item.Attempts))
+
+		switch item.Kind {
+		case queueKindIngestion:
+			if err := wp.db.UpdateIngestionJobStatus(ctx, item.IngestionJobID, types.StatusFailed, "worker lease expired after max attempts"); err != nil {
+				utils.Zlog.Error("Failed to mark ingestion job as failed",
+					zap.String("jobId", item.IngestionJobID),
+					zap.Error(err))
+				continue
+			}
+			wp.dispatcher.NotifyJob(ctx, item.IngestionJobID)
+		case queueKindEmbedding:
+			var job EmbeddingJob
+			if err := json.Unmarshal(item.Payload, &job); err != nil {
+				continue
+			}
+			if job.DatasourceID != "" {
+				if err := wp.db.UpdateJobDataSourceStatus(ctx, job.IngestionJobID, []string{job.DatasourceID}, "FAILED"); err != nil {
+					utils.Zlog.Error("Failed to update datasource status to FAILED",
+						zap.String("jobId", job.JobID),
+						zap.Error(err))
+				}
+			}
+			wp.finishSource(job, 0, "worker lease expired after max attempts")
+		}
+	}
 }
 
-func (wp *WorkerPool) processEmbeddingJob(workerID int, job EmbeddingJob) {
+func (wp *WorkerPool) processEmbeddingJob(jobCtx context.Context, workerID int, job EmbeddingJob) {
 	start := time.Now()
 	utils.Zlog.Info("Processing embedding job",
 		zap.Int("workerId", workerID),
@@ -148,44 +541,106 @@ func (wp *WorkerPool) processEmbeddingJob(workerID int, job EmbeddingJob) {
 		utils.Zlog.Warn("Embedder not configured, skipping embedding generation",
 			zap.Int("workerId", workerID),
 			zap.String("jobId", job.JobID))
+		wp.finishSource(job, 0, "embedder not configured")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(jobCtx, 5*time.Minute)
 	defer cancel()
 
+	textEmbedder, err := wp.embedderFor(ctx, job.ChatbotID)
+	if err != nil {
+		utils.Zlog.Error("Failed to resolve chatbot embedding settings",
+			zap.Int("workerId", workerID),
+			zap.String("jobId", job.JobID),
+			zap.String("chatbotId", job.ChatbotID),
+			zap.Error(err))
+		if jobCtx.Err() == nil {
+			wp.requeueFailedChunks(workerID, job, job.Chunks)
+		}
+		return
+	}
+
+	// Split chunks the model cannot take whole rather than failing on them
+	fitted, resplit := fitChunks(job.Chunks, textEmbedder.MaxInputTokens())
+	if resplit > 0 {
+		utils.Zlog.Info("Re-split chunks over the embedding input limit",
+			zap.Int("workerId", workerID),
+			zap.String("jobId", job.JobID),
+			zap.String("model", textEmbedder.ModelID()),
+			zap.Int("maxInputTokens", textEmbedder.MaxInputTokens()),
+			zap.Int("resplit", resplit),
+			zap.Int("chunks", len(fitted)))
+		job.Chunks = fitted
+	}
+
 	var successfulChunks []types.ContentChunk
 	var failedChunks []types.ContentChunk
+	var cacheStats embedder.CacheStats
+
+	// Generate embeddings in batches, one embedder call per batch
+	for batchStart := 0; batchStart < len(job.Chunks); batchStart += embedBatchSize {
+		if jobCtx.Err() != nil {
+			break
+		}
+		batchEnd := batchStart + embedBatchSize
+		if batchEnd > len(job.Chunks) {
+			batchEnd = len(job.Chunks)
+		}
 
-	// Generate embeddings for all chunks
-	for i := range job.Chunks {
-		embedding, err := wp.embedder.EmbedText(ctx, job.Chunks[i].Content)
+		var batch []int
+		var texts []string
+		for i := batchStart; i < batchEnd; i++ {
+			if job.Chunks[i].Content == "" {
+				failedChunks = append(failedChunks, job.Chunks[i])
+				continue
+			}
+			batch = append(batch, i)
+			texts = append(texts, job.Chunks[i].Content)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		embeddings, stats, err := wp.cache.EmbedBatch(ctx, textEmbedder, texts)
 		if err != nil {
-			utils.Zlog.Error("Failed to generate embedding",
+			utils.Zlog.Error("Failed to generate embeddings",
 				zap.Int("workerId", workerID),
 				zap.String("jobId", job.JobID),
-				zap.Int("chunkIndex", job.Chunks[i].ChunkIndex),
+				zap.Int("chunkIndex", job.Chunks[batch[0]].ChunkIndex),
+				zap.Int("batchSize", len(batch)),
 				zap.Error(err))
-			failedChunks = append(failedChunks, job.Chunks[i])
-			continue
+			for _, i := range batch {
+				failedChunks = append(failedChunks, job.Chunks[i])
+			}
+		} else {
+			for j, i := range batch {
+				job.Chunks[i].Embedding = embeddings[j]
+				successfulChunks = append(successfulChunks, job.Chunks[i])
+			}
+			cacheStats.Hits += stats.Hits
+			cacheStats.Misses += stats.Misses
+			utils.Zlog.Debug("Embedding batch generated",
+				zap.Int("workerId", workerID),
+				zap.String("jobId", job.JobID),
+				zap.Int("batchSize", len(batch)),
+				zap.Int("cacheHits", stats.Hits))
 		}
 
-		utils.Zlog.Debug("Embedding generated",
+		utils.Zlog.Info("Embedding progress",
 			zap.Int("workerId", workerID),
 			zap.String("jobId", job.JobID),
-			zap.Int("chunkIndex", job.Chunks[i].ChunkIndex),
-			zap.Int("embeddingLength", len(embedding)))
-
-		job.Chunks[i].Embedding = embedding
-		successfulChunks = append(successfulChunks, job.Chunks[i])
+			zap.Int("processed", len(successfulChunks)+len(failedChunks)),
+			zap.Int("total", len(job.Chunks)))
+		wp.chunksEmbedded(jobCtx, job, len(successfulChunks), len(failedChunks), cacheStats)
+	}
 
-		if (len(successfulChunks)+len(failedChunks))%10 == 0 {
-			utils.Zlog.Info("Embedding progress",
-				zap.Int("workerId", workerID),
-				zap.String("jobId", job.JobID),
-				zap.Int("processed", len(successfulChunks)+len(failedChunks)),
-				zap.Int("total", len(job.Chunks)))
-		}
+	if jobCtx.Err() != nil {
+		utils.Zlog.Info("Embedding job cancelled, discarding generated embeddings",
+			zap.Int("workerId", workerID),
+			zap.String("jobId", job.JobID),
+			zap.Int("discarded", len(successfulChunks)))
+		return
 	}
 
 	duration := time.Since(start)
@@ -193,38 +648,93 @@ func (wp *WorkerPool) processEmbeddingJob(workerID int, job EmbeddingJob) {
 		zap.Int("workerId", workerID),
 		zap.String("jobId", job.JobID),
 		zap.String("chatbotId", job.ChatbotID),
+		zap.String("model", textEmbedder.ModelID()),
 		zap.Int("successful", len(successfulChunks)),
 		zap.Int("failed", len(failedChunks)),
+		zap.Int("cacheHits", cacheStats.Hits),
+		zap.Int("cacheMisses", cacheStats.Misses),
 		zap.Duration("duration", duration))
 
 	// Persist successful embeddings to database
 	if wp.db != nil && len(successfulChunks) > 0 {
 		// Create a job copy with only successful chunks for persistence
 		successJob := EmbeddingJob{
-			JobID:     job.JobID,
-			UserID:    job.UserID,
-			ChatbotID: job.ChatbotID,
-			Chunks:    successfulChunks,
+			JobID:          job.JobID,
+			IngestionJobID: job.IngestionJobID,
+			DatasourceID:   job.DatasourceID,
+			UserID:         job.UserID,
+			ChatbotID:      job.ChatbotID,
+			Chunks:         successfulChunks,
+			Replace:        job.Replace,
+			EmbeddingModel: textEmbedder.ModelID(),
 		}
 
 		// Only mark COMPLETED if there are no failed chunks to retry
 		markCompleted := len(failedChunks) == 0
 
-		if err := wp.persistEmbeddingsWithStatus(ctx, successJob, markCompleted); err != nil {
-			utils.Zlog.Error("Failed to persist embeddings to database",
+		result, err := wp.persistEmbeddingsWithStatus(ctx, successJob, markCompleted)
+		if err != nil {
+			if errors.Is(err, loaders.ErrJobCancelled) {
+				utils.Zlog.Info("Ingestion job was cancelled, discarding its embeddings",
+					zap.Int("workerId", workerID),
+					zap.String("jobId", job.JobID),
+					zap.String("ingestionJobId", job.IngestionJobID))
+				return
+			}
+			if errors.Is(err, loaders.ErrEmbeddingSettingsChanged) {
+				utils.Zlog.Info("Chatbot was re-embedded while the job ran, embedding again with its new settings",
+					zap.Int("workerId", workerID),
+					zap.String("jobId", job.JobID),
+					zap.Error(err))
+			} else {
+				utils.Zlog.Error("Failed to persist embeddings to database",
+					zap.Int("workerId", workerID),
+					zap.String("jobId", job.JobID),
+					zap.Error(err))
+			}
+			if jobCtx.Err() != nil {
+				return
+			}
+			// Requeue entire job on persist failure; the retry resolves the
+			// chatbot's settings again
+			wp.requeueFailedChunks(workerID, job, job.Chunks)
+			return
+		}
+
+		rejections := result.Rejected
+		if len(rejections) > maxReportedRejections {
+			rejections = rejections[:maxReportedRejections]
+		}
+		recordEvent(jobCtx, wp.db, job.IngestionJobID, types.JobEventPersisted, job.DatasourceID, map[string]interface{}{
+			"embeddings": result.Inserted,
+			"rejected":   len(result.Rejected),
+			"rejections": rejections,
+			"completed":  markCompleted && result.Inserted > 0,
+		})
+
+		// Rejected rows would fail the same way again, so they are not retried
+		if result.Inserted == 0 && len(result.Rejected) > 0 {
+			utils.Zlog.Error("Database rejected every embedding of the job",
 				zap.Int("workerId", workerID),
 				zap.String("jobId", job.JobID),
-				zap.Error(err))
-			// Requeue entire job on persist failure
-			wp.requeueFailedChunks(workerID, job, job.Chunks)
+				zap.Int("rejected", len(result.Rejected)))
+			wp.finishSource(job, 0, fmt.Sprintf("all %d embeddings rejected by the database: %s",
+				len(result.Rejected), result.Rejected[0].Reason))
 			return
 		}
 
 		utils.Zlog.Info("Successfully persisted embeddings to database",
 			zap.Int("workerId", workerID),
 			zap.String("jobId", job.JobID),
-			zap.Int("embeddingsCount", len(successfulChunks)),
+			zap.Int("embeddingsCount", result.Inserted),
+			zap.Int("rejectedCount", len(result.Rejected)),
 			zap.Bool("markedCompleted", markCompleted))
+
+		if markCompleted {
+			wp.finishSource(job, result.Inserted, "")
+		} else {
+			wp.recordProgress(job, result.Inserted)
+		}
 	}
 
 	// Requeue failed chunks for retry
@@ -233,6 +743,59 @@ func (wp *WorkerPool) processEmbeddingJob(workerID int, job EmbeddingJob) {
 	}
 }
 
+// embedderFor returns the embedder configured for the chatbot, which may
+// differ from the global one in model, task type or dimensions
+func (wp *WorkerPool) embedderFor(ctx context.Context, chatbotID string) (embedder.Embedder, error) {
+	if wp.db == nil {
+		return wp.embedder, nil
+	}
+	return embedder.ForChatbot(ctx, wp.embedder, wp.db, chatbotID)
+}
+
+// fitChunks splits the chunks longer than maxTokens into parts that fit and
+// returns how many were split. Parts keep their chunk's metadata, record their
+// position in "part" and take consecutive chunk indexes, shifting the chunks
+// after them so every index stays unique within the job's datasource.
+func fitChunks(chunks []types.ContentChunk, maxTokens int) ([]types.ContentChunk, int) {
+	if maxTokens <= 0 {
+		return chunks, 0
+	}
+
+	var fitted []types.ContentChunk
+	resplit, shift := 0, 0
+	for i, chunk := range chunks {
+		if utils.DefaultTokenizer.CountTokens(chunk.Content) <= maxTokens {
+			if fitted != nil {
+				chunk.ChunkIndex += shift
+				fitted = append(fitted, chunk)
+			}
+			continue
+		}
+		if fitted == nil {
+			fitted = append(make([]types.ContentChunk, 0, len(chunks)+1), chunks[:i]...)
+		}
+		resplit++
+
+		parts := utils.NewTokenChunker(maxTokens, 0, utils.DefaultTokenizer).ChunkText(chunk.Content)
+		for n, part := range parts {
+			sub := chunk
+			sub.Content = part
+			sub.ChunkIndex = chunk.ChunkIndex + shift + n
+			sub.Metadata = maps.Clone(chunk.Metadata)
+			if sub.Metadata == nil {
+				sub.Metadata = map[string]interface{}{}
+			}
+			sub.Metadata["part"] = n
+			fitted = append(fitted, sub)
+		}
+		shift += max(len(parts)-1, 0)
+	}
+	if fitted == nil {
+		return chunks, 0
+	}
+	return fitted, resplit
+}
+
 // requeueFailedChunks requeues failed chunks for retry
 func (wp *WorkerPool) requeueFailedChunks(workerID int, originalJob EmbeddingJob, failedChunks []types.ContentChunk) {
 	if originalJob.RetryCount >= maxEmbeddingRetries {
@@ -257,7 +820,7 @@ func (wp *WorkerPool) requeueFailedChunks(workerID int, originalJob EmbeddingJob
 				}
 				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 				defer cancel()
-				if err := wp.db.UpdateDataSourceStatus(ctx, ids, "FAILED"); err != nil {
+				if err := wp.db.UpdateJobDataSourceStatus(ctx, originalJob.IngestionJobID, ids, "FAILED"); err != nil {
 					utils.Zlog.Error("Failed to update datasource status to FAILED",
 						zap.String("jobId", originalJob.JobID),
 						zap.Error(err))
@@ -268,20 +831,24 @@ func (wp *WorkerPool) requeueFailedChunks(workerID int, originalJob EmbeddingJob
 				}
 			}
 		}
+		wp.finishSource(originalJob, 0, "embedding failed after max retries")
 		return
 	}
 
 	retryJob := EmbeddingJob{
-		JobID:      originalJob.JobID + "-retry",
-		UserID:     originalJob.UserID,
-		ChatbotID:  originalJob.ChatbotID,
-		Chunks:     failedChunks,
-		CreatedAt:  time.Now().UTC(),
-		RetryCount: originalJob.RetryCount + 1,
+		JobID:          originalJob.JobID + "-retry",
+		IngestionJobID: originalJob.IngestionJobID,
+		DatasourceID:   originalJob.DatasourceID,
+		UserID:         originalJob.UserID,
+		ChatbotID:      originalJob.ChatbotID,
+		Chunks:         failedChunks,
+		CreatedAt:      time.Now().UTC(),
+		RetryCount:     originalJob.RetryCount + 1,
+		Replace:        originalJob.Replace,
 	}
 
 	if ok := wp.Enqueue(retryJob); !ok {
-		utils.Zlog.Error("Failed to requeue embedding job (queue full), marking datasources as FAILED",
+		utils.Zlog.Error("Failed to requeue embedding job, marking datasources as FAILED",
 			zap.Int("workerId", workerID),
 			zap.String("jobId", retryJob.JobID),
 			zap.Int("failedChunks", len(failedChunks)))
@@ -301,13 +868,14 @@ func (wp *WorkerPool) requeueFailedChunks(workerID int, originalJob EmbeddingJob
 				}
 				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 				defer cancel()
-				if err := wp.db.UpdateDataSourceStatus(ctx, ids, "FAILED"); err != nil {
+				if err := wp.db.UpdateJobDataSourceStatus(ctx, originalJob.IngestionJobID, ids, "FAILED"); err != nil {
 					utils.Zlog.Error("Failed to update datasource status to FAILED",
 						zap.String("jobId", originalJob.JobID),
 						zap.Error(err))
 				}
 			}
 		}
+		wp.finishSource(originalJob, 0, "failed to requeue embedding job")
 	} else {
 		utils.Zlog.Info("Requeued failed chunks for retry",
 			zap.Int("workerId", workerID),
@@ -317,16 +885,111 @@ func (wp *WorkerPool) requeueFailedChunks(workerID int, originalJob EmbeddingJob
 	}
 }
 
+// chunksEmbedded reports embedding progress of the job's datasource, and how
+// many chunks the embedding cache served, to SSE subscribers
+func (wp *WorkerPool) chunksEmbedded(ctx context.Context, job EmbeddingJob, embedded, failed int, cacheStats embedder.CacheStats) {
+	recordEvent(ctx, wp.db, job.IngestionJobID, types.JobEventChunksEmbedded, job.DatasourceID, map[string]interface{}{
+		"embedded":    embedded,
+		"failed":      failed,
+		"total":       len(job.Chunks),
+		"retryCount":  job.RetryCount,
+		"cacheHits":   cacheStats.Hits,
+		"cacheMisses": cacheStats.Misses,
+	})
+}
+
+// recordProgress adds persisted chunks to the ingestion job while retries are outstanding
+func (wp *WorkerPool) recordProgress(job EmbeddingJob, embeddedChunks int) {
+	if wp.db == nil || job.IngestionJobID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := wp.db.AddIngestionJobProgress(ctx, job.IngestionJobID, job.DatasourceID, embeddedChunks); err != nil {
+		utils.Zlog.Error("Failed to record ingestion job progress",
+			zap.String("jobId", job.JobID),
+			zap.String("ingestionJobId", job.IngestionJobID),
+			zap.Error(err))
+	}
+}
+
+// finishSource records the final embedding outcome of the job's datasource
+func (wp *WorkerPool) finishSource(job EmbeddingJob, embeddedChunks int, errorMessage string) {
+	if wp.db == nil || job.IngestionJobID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if errorMessage != "" && job.Replace {
+		// Keep serving the previous generation rather than a partial new one
+		wp.discardPartialEmbeddings(ctx, job.IngestionJobID, job.DatasourceID)
+	}
+
+	outcome, err := wp.db.FinishIngestionJobSource(ctx, job.IngestionJobID, job.DatasourceID, embeddedChunks, errorMessage)
+	if err != nil {
+		utils.Zlog.Error("Failed to record ingestion job source outcome",
+			zap.String("jobId", job.JobID),
+			zap.String("ingestionJobId", job.IngestionJobID),
+			zap.Error(err))
+		return
+	}
+	utils.Zlog.Info("Recorded datasource outcome",
+		zap.String("ingestionJobId", job.IngestionJobID),
+		zap.String("datasourceId", job.DatasourceID),
+		zap.String("jobStatus", string(outcome.JobStatus)))
+
+	if outcome.SourceUpdated {
+		wp.dispatcher.NotifyDatasource(ctx, job.IngestionJobID, job.DatasourceID)
+	}
+	if outcome.JobFinished {
+		wp.dispatcher.NotifyJob(ctx, job.IngestionJobID)
+	}
+}
+
+// discardPartialEmbeddings removes the rows a replacing job wrote for a datasource it could not finish
+func (wp *WorkerPool) discardPartialEmbeddings(ctx context.Context, ingestionJobID, datasourceID string) {
+	removed, err := wp.db.DeleteIngestionJobEmbeddings(ctx, ingestionJobID, datasourceID)
+	if err != nil {
+		utils.Zlog.Error("Failed to discard partial embeddings",
+			zap.String("ingestionJobId", ingestionJobID),
+			zap.String("datasourceId", datasourceID),
+			zap.Error(err))
+		return
+	}
+	if removed > 0 {
+		utils.Zlog.Info("Discarded partial embeddings of unfinished replacement",
+			zap.String("ingestionJobId", ingestionJobID),
+			zap.String("datasourceId", datasourceID),
+			zap.Int64("removed", removed))
+	}
+}
+
 // persistEmbeddings saves embeddings to the database and optionally updates data source status
-func (wp *WorkerPool) persistEmbeddings(ctx context.Context, job EmbeddingJob) error {
+func (wp *WorkerPool) persistEmbeddings(ctx context.Context, job EmbeddingJob) (*loaders.InsertResult, error) {
 	return wp.persistEmbeddingsWithStatus(ctx, job, true)
 }
 
-// persistEmbeddingsWithStatus saves embeddings and optionally marks datasources as COMPLETED
-func (wp *WorkerPool) persistEmbeddingsWithStatus(ctx context.Context, job EmbeddingJob, markCompleted bool) error {
+// chunkMetadata returns the metadata to store with a chunk, leaving out the
+// keys that already have their own column
+func chunkMetadata(metadata map[string]interface{}) map[string]interface{} {
+	stored := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		switch k {
+		case "citation", "datasourceId", "topic":
+			continue
+		}
+		stored[k] = v
+	}
+	return stored
+}
+
+// persistEmbeddingsWithStatus saves embeddings and updates data source status.
+// Datasources whose every chunk the database rejected are marked FAILED; the
+// others are marked COMPLETED when markCompleted is set.
+func (wp *WorkerPool) persistEmbeddingsWithStatus(ctx context.Context, job EmbeddingJob, markCompleted bool) (*loaders.InsertResult, error) {
 	// Prepare embedding data for insertion
 	var embeddingData []loaders.EmbeddingData
-	dataSourceIDsMap := make(map[string]bool)
+	chunksByDataSource := make(map[string]int)
 
 	for _, chunk := range job.Chunks {
 		if len(chunk.Embedding) == 0 {
@@ -342,46 +1005,103 @@ func (wp *WorkerPool) persistEmbeddingsWithStatus(ctx context.Context, job Embed
 		var dataSourceID *string
 		if chunk.DatasourceID != "" {
 			dataSourceID = &chunk.DatasourceID
-			dataSourceIDsMap[chunk.DatasourceID] = true
+			chunksByDataSource[chunk.DatasourceID]++
+		}
+
+		var ingestionJobID *string
+		if job.IngestionJobID != "" {
+			ingestionJobID = &job.IngestionJobID
 		}
 
+		topic, _ := chunk.Metadata["topic"].(string)
+		chunkIndex := chunk.ChunkIndex
+
 		embeddingData = append(embeddingData, loaders.EmbeddingData{
-			Text:         chunk.Content,
-			Vector:       chunk.Embedding,
-			DataSourceID: dataSourceID,
-			Citation:     citation,
+			Topic:          topic,
+			Text:           chunk.Content,
+			Vector:         chunk.Embedding,
+			DataSourceID:   dataSourceID,
+			Citation:       citation,
+			IngestionJobID: ingestionJobID,
+			Metadata:       chunkMetadata(chunk.Metadata),
+			ChunkIndex:     &chunkIndex,
+			Model:          job.EmbeddingModel,
 		})
 	}
 
 	if len(embeddingData) == 0 {
-		return nil
+		return &loaders.InsertResult{}, nil
+	}
+
+	// Insert embeddings into database. The last batch of a replacing job also
+	// removes the previous generation, in the same transaction.
+	var result *loaders.InsertResult
+	var err error
+	if job.Replace && markCompleted && job.IngestionJobID != "" && job.DatasourceID != "" {
+		result, err = wp.db.ReplaceEmbeddings(ctx, job.UserID, job.ChatbotID, job.IngestionJobID, job.DatasourceID, embeddingData)
+		if err != nil {
+			return nil, err
+		}
+		utils.Zlog.Info("Replaced previous embeddings",
+			zap.String("jobId", job.JobID),
+			zap.String("datasourceId", job.DatasourceID),
+			zap.Int64("removed", result.Removed))
+	} else {
+		result, err = wp.db.BatchInsertEmbeddings(ctx, job.UserID, job.ChatbotID, embeddingData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rejectedByDataSource := make(map[string]int)
+	for _, rejected := range result.Rejected {
+		rejectedByDataSource[rejected.DataSourceID]++
+	}
+	if len(result.Rejected) > 0 {
+		utils.Zlog.Warn("Database rejected embeddings",
+			zap.String("jobId", job.JobID),
+			zap.Int("inserted", result.Inserted),
+			zap.Int("rejected", len(result.Rejected)),
+			zap.String("firstReason", result.Rejected[0].Reason))
 	}
 
-	// Insert embeddings into database
-	if err := wp.db.BatchInsertEmbeddings(ctx, job.UserID, job.ChatbotID, embeddingData); err != nil {
-		return err
+	var completedIDs, failedIDs []string
+	for id, count := range chunksByDataSource {
+		if rejectedByDataSource[id] == count {
+			failedIDs = append(failedIDs, id)
+		} else if markCompleted {
+			completedIDs = append(completedIDs, id)
+		}
 	}
 
-	// Update data source status to COMPLETED only if all chunks succeeded
-	if markCompleted && len(dataSourceIDsMap) > 0 {
-		var dataSourceIDs []string
-		for id := range dataSourceIDsMap {
-			dataSourceIDs = append(dataSourceIDs, id)
+	if len(failedIDs) > 0 {
+		if err := wp.db.UpdateJobDataSourceStatus(ctx, job.IngestionJobID, failedIDs, "FAILED"); err != nil {
+			utils.Zlog.Error("Failed to update data source status",
+				zap.String("jobId", job.JobID),
+				zap.Error(err))
+			return nil, err
 		}
 
-		if err := wp.db.UpdateDataSourceStatus(ctx, dataSourceIDs, "COMPLETED"); err != nil {
+		utils.Zlog.Info("Marked datasources with only rejected embeddings as FAILED",
+			zap.String("jobId", job.JobID),
+			zap.Int("dataSourceCount", len(failedIDs)))
+	}
+
+	// Update data source status to COMPLETED only if all chunks succeeded
+	if len(completedIDs) > 0 {
+		if err := wp.db.UpdateJobDataSourceStatus(ctx, job.IngestionJobID, completedIDs, "COMPLETED"); err != nil {
 			utils.Zlog.Error("Failed to update data source status",
 				zap.String("jobId", job.JobID),
 				zap.Error(err))
-			return err
+			return nil, err
 		}
 
 		utils.Zlog.Info("Updated data source status to COMPLETED",
 			zap.String("jobId", job.JobID),
-			zap.Int("dataSourceCount", len(dataSourceIDs)))
+			zap.Int("dataSourceCount", len(completedIDs)))
 	}
 
-	return nil
+	return result, nil
 }
 
 // service.go - Mark FAILED on chunking/download failures:
@@ -392,4 +1112,4 @@ func (wp *WorkerPool) persistEmbeddingsWithStatus(ctx context.Context, job Embed
 // Failed chunks get requeued instead of being dropped
 // Only marks datasource COMPLETED when ALL chunks succeed
 // After max retries exhausted → marks datasource as FAILED
-// If queue full during requeue → marks datasource as FAILED
+// If requeue fails → marks datasource as FAILED
@@ -0,0 +1,109 @@
+package ingestion
+
+import (
+	"testing"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+func TestFitChunks(t *testing.T) {
+	// Each four-letter word estimates to one token
+	chunk := func(index int, content string) types.ContentChunk {
+		return types.ContentChunk{
+			DatasourceID: "ds",
+			Content:      content,
+			ChunkIndex:   index,
+			Metadata:     map[string]interface{}{"topic": "test"},
+		}
+	}
+
+	type part struct {
+		index   int
+		content string
+		part    int // -1 when the chunk was not split
+	}
+
+	tests := []struct {
+		name        string
+		chunks      []types.ContentChunk
+		maxTokens   int
+		want        []part
+		wantResplit int
+	}{
+		{
+			name:      "no limit",
+			chunks:    []types.ContentChunk{chunk(0, "aaaa bbbb cccc dddd")},
+			maxTokens: 0,
+			want:      []part{{0, "aaaa bbbb cccc dddd", -1}},
+		},
+		{
+			name:      "all fit",
+			chunks:    []types.ContentChunk{chunk(0, "aaaa bbbb"), chunk(1, "cccc")},
+			maxTokens: 3,
+			want:      []part{{0, "aaaa bbbb", -1}, {1, "cccc", -1}},
+		},
+		{
+			name:      "middle chunk split",
+			chunks:    []types.ContentChunk{chunk(0, "aaaa"), chunk(1, "bbbb cccc dddd eeee ffff"), chunk(2, "gggg")},
+			maxTokens: 3,
+			want: []part{
+				{0, "aaaa", -1},
+				{1, "bbbb cccc dddd", 0},
+				{2, "eeee ffff", 1},
+				{3, "gggg", -1},
+			},
+			wantResplit: 1,
+		},
+		{
+			name:      "consecutive chunks split",
+			chunks:    []types.ContentChunk{chunk(0, "aaaa bbbb cccc"), chunk(1, "dddd eeee ffff"), chunk(2, "gggg")},
+			maxTokens: 2,
+			want: []part{
+				{0, "aaaa bbbb", 0},
+				{1, "cccc", 1},
+				{2, "dddd eeee", 0},
+				{3, "ffff", 1},
+				{4, "gggg", -1},
+			},
+			wantResplit: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fitted, resplit := fitChunks(tt.chunks, tt.maxTokens)
+			if resplit != tt.wantResplit {
+				t.Errorf("resplit = %d, want %d", resplit, tt.wantResplit)
+			}
+			if len(fitted) != len(tt.want) {
+				t.Fatalf("got %d chunks, want %d", len(fitted), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := fitted[i]
+				if got.ChunkIndex != want.index || got.Content != want.content {
+					t.Errorf("chunk %d = (%d, %q), want (%d, %q)", i, got.ChunkIndex, got.Content, want.index, want.content)
+				}
+				if got.DatasourceID != "ds" || got.Metadata["topic"] != "test" {
+					t.Errorf("chunk %d lost its datasource or metadata: %+v", i, got)
+				}
+				part, split := got.Metadata["part"]
+				if want.part < 0 && split {
+					t.Errorf("chunk %d has part %v, want none", i, part)
+				}
+				if want.part >= 0 && part != want.part {
+					t.Errorf("chunk %d has part %v, want %d", i, part, want.part)
+				}
+			}
+
+			// Splitting must not renumber or tag the caller's chunks
+			for i, original := range tt.chunks {
+				if original.ChunkIndex != i {
+					t.Errorf("input chunk %d was renumbered to %d", i, original.ChunkIndex)
+				}
+				if _, ok := original.Metadata["part"]; ok {
+					t.Errorf("input chunk %d was tagged with a part", i)
+				}
+			}
+		})
+	}
+}
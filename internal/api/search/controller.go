@@ -0,0 +1,80 @@
+package search
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+var validate = validator.New()
+
+// Controller handles HTTP requests for retrieval
+type Controller struct {
+	service *Service
+}
+
+// NewController creates a new search controller
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// Search godoc
+// @Summary Search a chatbot's embeddings
+// @Description Embed the query for retrieval and return the most similar chunks by cosine similarity. Hybrid mode fuses the vector ranking with a full-text ranking using weighted reciprocal rank fusion.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param chatbotId path string true "Chatbot ID"
+// @Param request body types.SearchRequest true "Search Request"
+// @Success 200 {object} types.SearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/chatbots/{chatbotId}/search [post]
+func (ctrl *Controller) Search(c *gin.Context) {
+	chatbotID := c.Param("chatbotId")
+
+	var req types.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.ErrorResponse{
+			Error:     "Bad Request",
+			Message:   "invalid request: " + err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+
+	response, err := ctrl.service.Search(c.Request.Context(), chatbotID, req)
+	if errors.Is(err, ErrEmbedderUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, types.ErrorResponse{
+			Error:     "Service Unavailable",
+			Message:   "Search is unavailable: no embedder is configured",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		utils.Zlog.Error("Search failed", zap.String("chatbotId", chatbotID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,12 @@
+package search
+
+import (
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, db *loaders.PostgresClient, textEmbedder embedder.Embedder) {
+	controller := NewController(NewService(db, textEmbedder))
+	router.POST("/chatbots/:chatbotId/search", controller.Search)
+}
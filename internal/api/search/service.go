@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+const defaultTopK = 5
+
+// ErrEmbedderUnavailable is returned when no embedder is configured to embed queries
+var ErrEmbedderUnavailable = errors.New("embedder not configured")
+
+type Service struct {
+	db       *loaders.PostgresClient
+	embedder embedder.Embedder
+}
+
+func NewService(db *loaders.PostgresClient, textEmbedder embedder.Embedder) *Service {
+	return &Service{db: db, embedder: textEmbedder}
+}
+
+// Search embeds the query and returns the chatbot's most similar chunks
+func (s *Service) Search(ctx context.Context, chatbotID string, req types.SearchRequest) (*types.SearchResponse, error) {
+	if s.embedder == nil {
+		return nil, ErrEmbedderUnavailable
+	}
+
+	start := time.Now()
+	topK := req.TopK
+	if topK == 0 {
+		topK = defaultTopK
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = types.SearchModeVector
+	}
+
+	// Queries are embedded like the chatbot's chunks and only compared to
+	// chunks of the same model and size
+	textEmbedder, err := embedder.ForChatbot(ctx, s.embedder, s.db, chatbotID)
+	if err != nil {
+		return nil, err
+	}
+
+	vector, err := textEmbedder.EmbedQuery(ctx, req.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	filter := loaders.SearchFilter{
+		DatasourceIDs: req.DatasourceIDs,
+		Metadata:      req.MetadataFilter,
+	}
+
+	var results []types.SearchResult
+	if mode == types.SearchModeHybrid {
+		weights := loaders.HybridWeights{Vector: 1, Keyword: 1}
+		if req.VectorWeight != nil {
+			weights.Vector = *req.VectorWeight
+		}
+		if req.KeywordWeight != nil {
+			weights.Keyword = *req.KeywordWeight
+		}
+		results, err = s.db.HybridSearchEmbeddings(ctx, chatbotID, textEmbedder.ModelID(), vector, req.Query, topK, filter, weights)
+	} else {
+		results, err = s.db.SearchEmbeddings(ctx, chatbotID, textEmbedder.ModelID(), vector, topK, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.SearchResponse{
+		ChatbotID:  chatbotID,
+		Query:      req.Query,
+		TopK:       topK,
+		Mode:       mode,
+		ModelID:    textEmbedder.ModelID(),
+		Dimensions: textEmbedder.Dimensions(),
+		Results:    results,
+		TookMs:     time.Since(start).Milliseconds(),
+		Timestamp:  time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Controller handles HTTP requests for webhook deliveries
+type Controller struct {
+	service *Service
+}
+
+// NewController creates a new webhooks controller
+func NewController(service *Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries of a job
+// @Description List every callback sent for a job with its status and attempt history
+// @Tags webhooks
+// @Produce json
+// @Param jobId path string true "Job ID"
+// @Success 200 {array} types.WebhookDelivery
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/jobs/{jobId}/webhooks [get]
+func (ctrl *Controller) ListDeliveries(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	deliveries, err := ctrl.service.ListDeliveries(c.Request.Context(), jobID)
+	if errors.Is(err, loaders.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:     "Not Found",
+			Message:   "Job not found",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		utils.Zlog.Error("Failed to list webhook deliveries", zap.String("jobId", jobID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Replay godoc
+// @Summary Replay a webhook delivery
+// @Description Send a delivery again with a fresh attempt budget, whatever its current status
+// @Tags webhooks
+// @Produce json
+// @Param deliveryId path string true "Delivery ID"
+// @Success 202 {object} types.WebhookDelivery
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/deliveries/{deliveryId}/replay [post]
+func (ctrl *Controller) Replay(c *gin.Context) {
+	deliveryID := c.Param("deliveryId")
+
+	delivery, err := ctrl.service.Replay(c.Request.Context(), deliveryID)
+	if errors.Is(err, loaders.ErrNotFound) {
+		c.JSON(http.StatusNotFound, types.ErrorResponse{
+			Error:     "Not Found",
+			Message:   "Delivery not found",
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	if err != nil {
+		utils.Zlog.Error("Failed to replay webhook delivery", zap.String("deliveryId", deliveryID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, types.ErrorResponse{
+			Error:     "Internal Server Error",
+			Message:   err.Error(),
+			Timestamp: time.Now().UTC(),
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,13 @@
+package webhooks
+
+import (
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.RouterGroup, db *loaders.PostgresClient, dispatcher *webhooks.Dispatcher) {
+	controller := NewController(NewService(db, dispatcher))
+	router.GET("/jobs/:jobId/webhooks", controller.ListDeliveries)
+	router.POST("/webhooks/deliveries/:deliveryId/replay", controller.Replay)
+}
@@ -0,0 +1,34 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
+)
+
+type Service struct {
+	db         *loaders.PostgresClient
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewService(db *loaders.PostgresClient, dispatcher *webhooks.Dispatcher) *Service {
+	return &Service{db: db, dispatcher: dispatcher}
+}
+
+// ListDeliveries returns the webhook deliveries of a job with their attempt history
+func (s *Service) ListDeliveries(ctx context.Context, jobID string) ([]types.WebhookDelivery, error) {
+	if _, err := s.db.GetIngestionJob(ctx, jobID); err != nil {
+		return nil, err
+	}
+	return s.db.ListWebhookDeliveries(ctx, jobID)
+}
+
+// Replay makes a delivery due again and returns its current state
+func (s *Service) Replay(ctx context.Context, deliveryID string) (*types.WebhookDelivery, error) {
+	if err := s.dispatcher.Replay(ctx, deliveryID); err != nil {
+		return nil, err
+	}
+	return s.db.GetWebhookDelivery(ctx, deliveryID)
+}
@@ -2,11 +2,22 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"time"
+)
+
+// Process roles selectable with --role or ROLE
+const (
+	RoleAPI    = "api"
+	RoleWorker = "worker"
+	RoleAll    = "all"
 )
 
 type Config struct {
+	Role           string
 	DatabaseURL    string
 	LogLevel       string
 	Debug          bool
@@ -19,6 +30,50 @@ type Config struct {
 	Port           string
 	AllowedOrigins []string
 	GeminiAPIKeys  []string
+
+	// Per-key Gemini limits, zero means unlimited
+	GeminiRequestsPerMinute int
+	GeminiTokensPerMinute   int
+
+	EmbeddingProvider   string
+	EmbeddingModel      string
+	EmbeddingTaskType   string
+	EmbeddingDimensions int
+	EmbeddingBaseURL    string
+	EmbeddingAPIKey     string
+
+	// Fallbacks tried in order when the primary provider fails: a second
+	// Gemini project, then an OpenAI-compatible endpoint
+	GeminiFallbackAPIKeys    []string
+	EmbeddingFallbackBaseURL string
+	EmbeddingFallbackAPIKey  string
+	EmbeddingFallbackModel   string
+
+	EmbeddingCache        bool
+	EmbeddingCacheLRUSize int
+
+	QueuePollInterval  time.Duration
+	QueueLeaseDuration time.Duration
+	QueueMaxAttempts   int
+
+	// How long progress events and dead work items are kept
+	JobEventRetention time.Duration
+	DeadWorkRetention time.Duration
+
+	WebhookSecret      string
+	WebhookMaxAttempts int
+
+	IdempotencyWindow time.Duration
+
+	UploadSpoolDir string
+	UploadMaxBytes int64
+
+	// uploadSpoolDirSet is false when UploadSpoolDir is the local default
+	uploadSpoolDirSet bool
+
+	SearchLanguage string
+
+	AutoMigrate bool
 }
 
 func LoadConfig() (*Config, error) {
@@ -33,29 +88,12 @@ func LoadConfig() (*Config, error) {
 			allowedOrigins = append(allowedOrigins, string(origin))
 		}
 	}
-	// Load comma-separated Gemini API keys
-	var geminiAPIKeys []string
-	if keys := os.Getenv("GEMINI_API_KEYS"); keys != "" {
-		// split by comma and trim spaces
-		current := ""
-		for _, ch := range keys {
-			if ch == ',' {
-				if current != "" {
-					geminiAPIKeys = append(geminiAPIKeys, current)
-					current = ""
-				}
-				continue
-			}
-			if ch == ' ' || ch == '\n' || ch == '\t' || ch == '\r' {
-				// skip whitespace around commas
-				continue
-			}
-			current += string(ch)
-		}
-		if current != "" {
-			geminiAPIKeys = append(geminiAPIKeys, current)
-		}
+	geminiAPIKeys := splitAPIKeys(os.Getenv("GEMINI_API_KEYS"))
+	role := os.Getenv("ROLE")
+	if role == "" {
+		role = RoleAll
 	}
+
 	databaseUrl := os.Getenv("DATABASE_URL")
 	if databaseUrl == "" {
 		return nil, errors.New("DATABASE_URL is required")
@@ -105,7 +143,107 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	queuePollInterval := 2 * time.Second // default value
+	if pi := os.Getenv("QUEUE_POLL_INTERVAL"); pi != "" {
+		if parsed, err := time.ParseDuration(pi); err == nil && parsed > 0 {
+			queuePollInterval = parsed
+		}
+	}
+
+	queueLeaseDuration := 2 * time.Minute // default value
+	if ld := os.Getenv("QUEUE_LEASE_DURATION"); ld != "" {
+		if parsed, err := time.ParseDuration(ld); err == nil && parsed > 0 {
+			queueLeaseDuration = parsed
+		}
+	}
+
+	queueMaxAttempts := 5 // default value
+	if ma := os.Getenv("QUEUE_MAX_ATTEMPTS"); ma != "" {
+		if parsed, err := strconv.Atoi(ma); err == nil && parsed > 0 {
+			queueMaxAttempts = parsed
+		}
+	}
+
+	jobEventRetention := 7 * 24 * time.Hour // default value
+	if er := os.Getenv("JOB_EVENT_RETENTION"); er != "" {
+		if parsed, err := time.ParseDuration(er); err == nil && parsed > 0 {
+			jobEventRetention = parsed
+		}
+	}
+
+	deadWorkRetention := 7 * 24 * time.Hour // default value
+	if dr := os.Getenv("DEAD_WORK_RETENTION"); dr != "" {
+		if parsed, err := time.ParseDuration(dr); err == nil && parsed > 0 {
+			deadWorkRetention = parsed
+		}
+	}
+
+	webhookMaxAttempts := 8 // default value
+	if ma := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); ma != "" {
+		if parsed, err := strconv.Atoi(ma); err == nil && parsed > 0 {
+			webhookMaxAttempts = parsed
+		}
+	}
+
+	idempotencyWindow := 24 * time.Hour // default value
+	if iw := os.Getenv("IDEMPOTENCY_WINDOW"); iw != "" {
+		if parsed, err := time.ParseDuration(iw); err == nil && parsed > 0 {
+			idempotencyWindow = parsed
+		}
+	}
+
+	uploadSpoolDir := os.Getenv("UPLOAD_SPOOL_DIR")
+	if uploadSpoolDir == "" {
+		uploadSpoolDir = filepath.Join(os.TempDir(), "db-ingestor-uploads")
+	}
+
+	uploadMaxBytes := int64(100 * 1024 * 1024) // default value
+	if mb := os.Getenv("UPLOAD_MAX_BYTES"); mb != "" {
+		if parsed, err := strconv.ParseInt(mb, 10, 64); err == nil && parsed > 0 {
+			uploadMaxBytes = parsed
+		}
+	}
+
+	embeddingProvider := os.Getenv("EMBEDDING_PROVIDER")
+	if embeddingProvider == "" {
+		embeddingProvider = "gemini"
+	}
+
+	embeddingDimensions := 0 // the provider's default
+	if ed := os.Getenv("EMBEDDING_DIMENSIONS"); ed != "" {
+		if parsed, err := strconv.Atoi(ed); err == nil && parsed > 0 {
+			embeddingDimensions = parsed
+		}
+	}
+
+	geminiRPM := 1500 // default value
+	if rpm := os.Getenv("GEMINI_RPM"); rpm != "" {
+		if parsed, err := strconv.Atoi(rpm); err == nil && parsed >= 0 {
+			geminiRPM = parsed
+		}
+	}
+
+	geminiTPM := 0 // default value, unlimited
+	if tpm := os.Getenv("GEMINI_TPM"); tpm != "" {
+		if parsed, err := strconv.Atoi(tpm); err == nil && parsed >= 0 {
+			geminiTPM = parsed
+		}
+	}
+
+	embeddingCacheLRUSize := 10000 // default value
+	if ls := os.Getenv("EMBEDDING_CACHE_LRU_SIZE"); ls != "" {
+		if parsed, err := strconv.Atoi(ls); err == nil && parsed >= 0 {
+			embeddingCacheLRUSize = parsed
+		}
+	}
+
+	searchLanguage := os.Getenv("SEARCH_LANGUAGE")
+	if searchLanguage == "" {
+		searchLanguage = "english"
+	}
+
 	return &Config{
+		Role:           role,
 		Port:           port,
 		AllowedOrigins: allowedOrigins,
 		DatabaseURL:    databaseUrl,
@@ -118,5 +256,98 @@ func LoadConfig() (*Config, error) {
 		WorkerCount:    workerCount,
 		BatchSize:      batchSize,
 		GeminiAPIKeys:  geminiAPIKeys,
+
+		GeminiRequestsPerMinute: geminiRPM,
+		GeminiTokensPerMinute:   geminiTPM,
+
+		EmbeddingProvider:   embeddingProvider,
+		EmbeddingModel:      os.Getenv("EMBEDDING_MODEL"),
+		EmbeddingTaskType:   os.Getenv("EMBEDDING_TASK_TYPE"),
+		EmbeddingDimensions: embeddingDimensions,
+		EmbeddingBaseURL:    os.Getenv("EMBEDDING_BASE_URL"),
+		EmbeddingAPIKey:     os.Getenv("EMBEDDING_API_KEY"),
+
+		GeminiFallbackAPIKeys:    splitAPIKeys(os.Getenv("GEMINI_FALLBACK_API_KEYS")),
+		EmbeddingFallbackBaseURL: os.Getenv("EMBEDDING_FALLBACK_BASE_URL"),
+		EmbeddingFallbackAPIKey:  os.Getenv("EMBEDDING_FALLBACK_API_KEY"),
+		EmbeddingFallbackModel:   os.Getenv("EMBEDDING_FALLBACK_MODEL"),
+
+		EmbeddingCache:        os.Getenv("EMBEDDING_CACHE") != "false",
+		EmbeddingCacheLRUSize: embeddingCacheLRUSize,
+
+		QueuePollInterval:  queuePollInterval,
+		QueueLeaseDuration: queueLeaseDuration,
+		QueueMaxAttempts:   queueMaxAttempts,
+
+		JobEventRetention: jobEventRetention,
+		DeadWorkRetention: deadWorkRetention,
+
+		WebhookSecret:      os.Getenv("WEBHOOK_SECRET"),
+		WebhookMaxAttempts: webhookMaxAttempts,
+
+		IdempotencyWindow: idempotencyWindow,
+
+		UploadSpoolDir: uploadSpoolDir,
+		UploadMaxBytes: uploadMaxBytes,
+
+		uploadSpoolDirSet: os.Getenv("UPLOAD_SPOOL_DIR") != "",
+
+		SearchLanguage: searchLanguage,
+
+		AutoMigrate: os.Getenv("AUTO_MIGRATE") == "true",
 	}, nil
 }
+
+// splitAPIKeys splits a comma-separated list of keys, skipping whitespace
+func splitAPIKeys(keys string) []string {
+	var split []string
+	current := ""
+	for _, ch := range keys {
+		if ch == ',' {
+			if current != "" {
+				split = append(split, current)
+				current = ""
+			}
+			continue
+		}
+		if ch == ' ' || ch == '\n' || ch == '\t' || ch == '\r' {
+			// skip whitespace around commas
+			continue
+		}
+		current += string(ch)
+	}
+	if current != "" {
+		split = append(split, current)
+	}
+	return split
+}
+
+// ValidateRole checks that Role is one of api, worker or all
+func (c *Config) ValidateRole() error {
+	switch c.Role {
+	case RoleAPI, RoleWorker, RoleAll:
+		return nil
+	default:
+		return fmt.Errorf("invalid role %q: must be one of api, worker, all", c.Role)
+	}
+}
+
+// ValidateUploadSpool requires UPLOAD_SPOOL_DIR when the api and worker roles
+// run as separate processes: the local default is not visible to the workers
+// that process the uploads, so it must point at storage both can reach
+func (c *Config) ValidateUploadSpool() error {
+	if c.Role == RoleAll || c.uploadSpoolDirSet {
+		return nil
+	}
+	return fmt.Errorf("UPLOAD_SPOOL_DIR is required with role %q: set it to storage shared by the api and worker processes", c.Role)
+}
+
+// RunsAPI reports whether this process serves the ingestion API
+func (c *Config) RunsAPI() bool {
+	return c.Role == RoleAPI || c.Role == RoleAll
+}
+
+// RunsWorkers reports whether this process consumes jobs from the work queue
+func (c *Config) RunsWorkers() bool {
+	return c.Role == RoleWorker || c.Role == RoleAll
+}
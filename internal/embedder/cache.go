@@ -0,0 +1,179 @@
+package embedder
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/Conversly/db-ingestor/internal/utils"
+)
+
+// CacheStore persists embeddings by the sha256 of their text
+type CacheStore interface {
+	GetCachedEmbeddings(ctx context.Context, model, taskType string, dimensions int, hashes [][32]byte) (map[[32]byte][]float32, error)
+	PutCachedEmbeddings(ctx context.Context, model, taskType string, dimensions int, vectors map[[32]byte][]float32) error
+}
+
+// CacheStats counts the texts served from the cache and those sent to the provider
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// Cache skips re-embedding text that has been embedded before with the same
+// model, task type and dimensions. Lookups go to an in-process LRU first, then to the
+// store; store errors are logged and treated as misses.
+type Cache struct {
+	store CacheStore
+	lru   *lruCache
+}
+
+// NewCache creates a cache over store. lruSize bounds the vectors kept in
+// memory; zero disables the in-process layer.
+func NewCache(store CacheStore, lruSize int) *Cache {
+	cache := &Cache{store: store}
+	if lruSize > 0 {
+		cache.lru = newLRUCache(lruSize)
+	}
+	return cache
+}
+
+// EmbedBatch returns document embeddings for texts, in order, embedding only
+// the distinct texts that are not cached. A nil cache calls textEmbedder directly.
+func (c *Cache) EmbedBatch(ctx context.Context, textEmbedder Embedder, texts []string) ([][]float32, CacheStats, error) {
+	if c == nil {
+		embeddings, err := textEmbedder.EmbedBatch(ctx, texts)
+		return embeddings, CacheStats{Misses: len(texts)}, err
+	}
+
+	model := textEmbedder.ModelID()
+	taskType := textEmbedder.TaskType()
+	dimensions := textEmbedder.Dimensions()
+	prefix := fmt.Sprintf("%s|%s|%d|", model, taskType, dimensions)
+
+	hashes := make([][32]byte, len(texts))
+	found := make(map[[32]byte][]float32)
+	var lookup [][32]byte
+	for i, text := range texts {
+		hashes[i] = sha256.Sum256([]byte(text))
+		if _, seen := found[hashes[i]]; seen {
+			continue
+		}
+		if vector, ok := c.lru.get(prefix + string(hashes[i][:])); ok {
+			found[hashes[i]] = vector
+			continue
+		}
+		found[hashes[i]] = nil
+		lookup = append(lookup, hashes[i])
+	}
+
+	if len(lookup) > 0 && c.store != nil {
+		stored, err := c.store.GetCachedEmbeddings(ctx, model, taskType, dimensions, lookup)
+		if err != nil {
+			utils.Zlog.Warn("Embedding cache lookup failed, embedding every text", zap.Error(err))
+		}
+		for hash, vector := range stored {
+			if len(vector) != dimensions {
+				continue
+			}
+			found[hash] = vector
+			c.lru.put(prefix+string(hash[:]), vector)
+		}
+	}
+
+	// Embed each missing text once, however often it repeats
+	var missTexts []string
+	var missHashes [][32]byte
+	queued := make(map[[32]byte]bool)
+	for i, text := range texts {
+		if found[hashes[i]] != nil || queued[hashes[i]] {
+			continue
+		}
+		queued[hashes[i]] = true
+		missTexts = append(missTexts, text)
+		missHashes = append(missHashes, hashes[i])
+	}
+
+	if len(missTexts) > 0 {
+		embedded, err := textEmbedder.EmbedBatch(ctx, missTexts)
+		if err != nil {
+			return nil, CacheStats{}, err
+		}
+		fresh := make(map[[32]byte][]float32, len(embedded))
+		for j, vector := range embedded {
+			found[missHashes[j]] = vector
+			fresh[missHashes[j]] = vector
+			c.lru.put(prefix+string(missHashes[j][:]), vector)
+		}
+		if c.store != nil {
+			if err := c.store.PutCachedEmbeddings(ctx, model, taskType, dimensions, fresh); err != nil {
+				utils.Zlog.Warn("Failed to store embeddings in cache", zap.Error(err))
+			}
+		}
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i := range texts {
+		embeddings[i] = found[hashes[i]]
+	}
+	return embeddings, CacheStats{Hits: len(texts) - len(missTexts), Misses: len(missTexts)}, nil
+}
+
+// lruCache is a fixed-size, least recently used map from key to vector. A nil
+// lruCache holds nothing.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key    string
+	vector []float32
+}
+
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+func (l *lruCache) get(key string) ([]float32, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).vector, true
+}
+
+func (l *lruCache) put(key string, vector []float32) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		elem.Value.(*lruEntry).vector = vector
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, vector: vector})
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
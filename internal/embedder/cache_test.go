@@ -0,0 +1,229 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Conversly/db-ingestor/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	utils.Zlog = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// stubEmbedder is a hash embedder that fails with err while it is set and
+// counts the calls it receives
+type stubEmbedder struct {
+	*HashEmbedder
+	err   error
+	calls int
+}
+
+func newStub(t *testing.T, settings Settings) *stubEmbedder {
+	t.Helper()
+	h, err := NewHashEmbedder(settings)
+	if err != nil {
+		t.Fatalf("NewHashEmbedder() error = %v", err)
+	}
+	return &stubEmbedder{HashEmbedder: h}
+}
+
+func (s *stubEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.HashEmbedder.EmbedText(ctx, text)
+}
+
+func (s *stubEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.HashEmbedder.EmbedBatch(ctx, texts)
+}
+
+func TestLRUCache(t *testing.T) {
+	vec := func(v float32) []float32 { return []float32{v} }
+
+	tests := []struct {
+		name    string
+		ops     func(l *lruCache)
+		present []string
+		absent  []string
+	}{
+		{
+			name: "evicts least recently put",
+			ops: func(l *lruCache) {
+				l.put("a", vec(1))
+				l.put("b", vec(2))
+				l.put("c", vec(3))
+			},
+			present: []string{"b", "c"},
+			absent:  []string{"a"},
+		},
+		{
+			name: "get refreshes recency",
+			ops: func(l *lruCache) {
+				l.put("a", vec(1))
+				l.put("b", vec(2))
+				l.get("a")
+				l.put("c", vec(3))
+			},
+			present: []string{"a", "c"},
+			absent:  []string{"b"},
+		},
+		{
+			name: "put refreshes recency without growing",
+			ops: func(l *lruCache) {
+				l.put("a", vec(1))
+				l.put("b", vec(2))
+				l.put("a", vec(4))
+				l.put("c", vec(3))
+			},
+			present: []string{"a", "c"},
+			absent:  []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLRUCache(2)
+			tt.ops(l)
+			if l.order.Len() != len(l.items) || len(l.items) > 2 {
+				t.Fatalf("size = %d items, %d in order, capacity 2", len(l.items), l.order.Len())
+			}
+			for _, key := range tt.present {
+				if _, ok := l.get(key); !ok {
+					t.Errorf("%q was evicted", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, ok := l.get(key); ok {
+					t.Errorf("%q was kept", key)
+				}
+			}
+		})
+	}
+
+	t.Run("updated value", func(t *testing.T) {
+		l := newLRUCache(2)
+		l.put("a", vec(1))
+		l.put("a", vec(2))
+		if got, _ := l.get("a"); got[0] != 2 {
+			t.Errorf("get() = %v, want the latest value", got)
+		}
+	})
+
+	t.Run("nil cache", func(t *testing.T) {
+		var l *lruCache
+		l.put("a", vec(1))
+		if _, ok := l.get("a"); ok {
+			t.Error("nil cache returned a value")
+		}
+	})
+}
+
+// memoryStore is a CacheStore over a map that can be made to fail
+type memoryStore struct {
+	vectors map[[32]byte][]float32
+	err     error
+}
+
+func (m *memoryStore) GetCachedEmbeddings(ctx context.Context, model, taskType string, dimensions int, hashes [][32]byte) (map[[32]byte][]float32, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	found := make(map[[32]byte][]float32)
+	for _, hash := range hashes {
+		if vector, ok := m.vectors[hash]; ok {
+			found[hash] = vector
+		}
+	}
+	return found, nil
+}
+
+func (m *memoryStore) PutCachedEmbeddings(ctx context.Context, model, taskType string, dimensions int, vectors map[[32]byte][]float32) error {
+	if m.err != nil {
+		return m.err
+	}
+	for hash, vector := range vectors {
+		m.vectors[hash] = vector
+	}
+	return nil
+}
+
+func TestCacheEmbedBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		lruSize   int
+		storeErr  error
+		batches   [][]string
+		wantStats []CacheStats
+		wantCalls int
+	}{
+		{
+			name:      "repeated text embedded once",
+			lruSize:   10,
+			batches:   [][]string{{"a", "b", "a"}},
+			wantStats: []CacheStats{{Hits: 1, Misses: 2}},
+			wantCalls: 1,
+		},
+		{
+			name:      "second batch served from memory",
+			lruSize:   10,
+			batches:   [][]string{{"a", "b"}, {"b", "a"}},
+			wantStats: []CacheStats{{Misses: 2}, {Hits: 2}},
+			wantCalls: 1,
+		},
+		{
+			name:      "second batch served from the store",
+			batches:   [][]string{{"a", "b"}, {"a", "c"}},
+			wantStats: []CacheStats{{Misses: 2}, {Hits: 1, Misses: 1}},
+			wantCalls: 2,
+		},
+		{
+			name:      "store errors are misses",
+			storeErr:  errors.New("database down"),
+			batches:   [][]string{{"a"}, {"a"}},
+			wantStats: []CacheStats{{Misses: 1}, {Misses: 1}},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			textEmbedder := newStub(t, Settings{Dimensions: 8})
+			cache := NewCache(&memoryStore{vectors: map[[32]byte][]float32{}, err: tt.storeErr}, tt.lruSize)
+
+			for i, texts := range tt.batches {
+				embeddings, stats, err := cache.EmbedBatch(context.Background(), textEmbedder, texts)
+				if err != nil {
+					t.Fatalf("batch %d: EmbedBatch() error = %v", i, err)
+				}
+				if stats != tt.wantStats[i] {
+					t.Errorf("batch %d: stats = %+v, want %+v", i, stats, tt.wantStats[i])
+				}
+
+				want, err := textEmbedder.HashEmbedder.EmbedBatch(context.Background(), texts)
+				if err != nil {
+					t.Fatalf("EmbedBatch() error = %v", err)
+				}
+				for j := range texts {
+					if dot(embeddings[j], want[j]) < 0.9999 {
+						t.Errorf("batch %d: embedding %d does not match %q", i, j, texts[j])
+					}
+				}
+			}
+			if textEmbedder.calls != tt.wantCalls {
+				t.Errorf("embedder calls = %d, want %d", textEmbedder.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+// Embedding providers selectable with EMBEDDING_PROVIDER
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderHash   = "hash"
+)
+
+// DefaultDimensions is the vector size the embeddings table is created with
+const DefaultDimensions = 768
+
+// maxConcurrentRequests bounds in-flight requests to a provider across all workers
+const maxConcurrentRequests = 5
+
+// Embedder turns text into vectors. Documents and queries are embedded
+// separately for providers that distinguish them for retrieval.
+type Embedder interface {
+	// EmbedText embeds a document chunk for storage
+	EmbedText(ctx context.Context, text string) ([]float32, error)
+	// EmbedQuery embeds a search query to compare against stored chunks
+	EmbedQuery(ctx context.Context, query string) ([]float32, error)
+	// EmbedBatch embeds several document chunks, in order
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+	// Dimensions is the length of every vector returned
+	Dimensions() int
+	// ModelID identifies the provider and model, e.g. "gemini/text-embedding-004"
+	ModelID() string
+	// TaskType is the task type documents are embedded with, empty when the
+	// provider has none
+	TaskType() string
+	// MaxInputTokens is the longest text the model accepts, in tokens
+	MaxInputTokens() int
+	// WithSettings returns an embedder for the same provider and credentials
+	// using settings where they are set
+	WithSettings(settings Settings) (Embedder, error)
+}
+
+// Settings selects the model, document task type and output dimensionality
+// of an embedder. Zero values keep the current setting.
+type Settings struct {
+	Model      string
+	TaskType   string
+	Dimensions int
+}
+
+// SettingsStore looks up per-chatbot embedding settings
+type SettingsStore interface {
+	GetChatbotEmbeddingSettings(ctx context.Context, chatbotID string) (*types.ChatbotEmbeddingSettings, error)
+}
+
+// ForChatbot returns base adjusted to the chatbot's stored settings, or base
+// itself when the chatbot has none
+func ForChatbot(ctx context.Context, base Embedder, store SettingsStore, chatbotID string) (Embedder, error) {
+	stored, err := store.GetChatbotEmbeddingSettings(ctx, chatbotID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return base, nil
+	}
+	textEmbedder, err := base.WithSettings(Settings{
+		Model:      stored.Model,
+		TaskType:   stored.TaskType,
+		Dimensions: stored.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding settings for chatbot %s: %w", chatbotID, err)
+	}
+	return textEmbedder, nil
+}
+
+// normalize normalizes a vector to unit length
+func normalize(vec []float32) []float32 {
+	if len(vec) == 0 {
+		return vec
+	}
+
+	norm := float32(0.0)
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = float32(math.Sqrt(float64(norm)))
+
+	if norm == 0 || math.IsNaN(float64(norm)) || math.IsInf(float64(norm), 0) {
+		return vec
+	}
+
+	normalized := make([]float32, len(vec))
+	for i, v := range vec {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
+
+// fitDimensions returns vec cut to the configured size. Providers that ignore
+// the requested dimensionality return the full Matryoshka vector, whose prefix
+// is itself an embedding once normalized again; shorter vectors are an error.
+func fitDimensions(vec []float32, dimensions int) ([]float32, error) {
+	if len(vec) == 0 {
+		return nil, fmt.Errorf("no embeddings returned from API")
+	}
+	if len(vec) < dimensions {
+		return nil, fmt.Errorf("expected %d dimensions, got %d", dimensions, len(vec))
+	}
+	return vec[:dimensions], nil
+}
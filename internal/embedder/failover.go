@@ -0,0 +1,272 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/Conversly/db-ingestor/internal/utils"
+)
+
+// A failed provider is skipped for a cooldown that doubles with each
+// consecutive failure, after which it is tried again first
+const (
+	failoverCooldownBase = 30 * time.Second
+	failoverCooldownMax  = 5 * time.Minute
+)
+
+// FailoverProvider is one named link of a failover chain
+type FailoverProvider struct {
+	Name     string
+	Embedder Embedder
+}
+
+// FailoverEmbedder sends each request to the first healthy provider of an
+// ordered chain and moves on when one fails. Only providers producing the
+// same model, task type and dimensions as the primary are used, so a chatbot
+// never gets vectors it cannot compare with its stored ones.
+type FailoverEmbedder struct {
+	chain  []failoverMember
+	active []failoverMember
+}
+
+// failoverMember pairs a provider with its health, which is shared by every
+// variant WithSettings derives from it
+type failoverMember struct {
+	name     string
+	embedder Embedder
+	health   *providerHealth
+}
+
+var _ Embedder = (*FailoverEmbedder)(nil)
+
+// NewFailoverEmbedder creates an embedder over providers, the first being
+// the primary. It fails when a fallback would produce vectors incompatible
+// with the primary's.
+func NewFailoverEmbedder(providers []FailoverProvider) (*FailoverEmbedder, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("at least one provider is required")
+	}
+
+	chain := make([]failoverMember, len(providers))
+	for i, provider := range providers {
+		chain[i] = failoverMember{
+			name:     provider.Name,
+			embedder: provider.Embedder,
+			health:   &providerHealth{name: provider.Name},
+		}
+	}
+
+	primary := chain[0].embedder
+	for _, member := range chain[1:] {
+		if !compatible(primary, member.embedder) {
+			return nil, fmt.Errorf("fallback %s produces %s (task type %q, %d dimensions), incompatible with primary %s (task type %q, %d dimensions)",
+				member.name, member.embedder.ModelID(), member.embedder.TaskType(), member.embedder.Dimensions(),
+				primary.ModelID(), primary.TaskType(), primary.Dimensions())
+		}
+	}
+	return &FailoverEmbedder{chain: chain, active: chain}, nil
+}
+
+// WithSettings applies settings to every provider. The primary must accept
+// them; fallbacks that reject them or no longer match it are left out.
+func (f *FailoverEmbedder) WithSettings(settings Settings) (Embedder, error) {
+	chain := make([]failoverMember, 0, len(f.chain))
+	for i, member := range f.chain {
+		variant, err := member.embedder.WithSettings(settings)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			continue
+		}
+		member.embedder = variant
+		chain = append(chain, member)
+	}
+	return &FailoverEmbedder{chain: chain, active: compatibleMembers(chain)}, nil
+}
+
+// Dimensions returns the primary's output dimensionality
+func (f *FailoverEmbedder) Dimensions() int {
+	return f.active[0].embedder.Dimensions()
+}
+
+// ModelID returns the primary's model, which every active fallback shares
+func (f *FailoverEmbedder) ModelID() string {
+	return f.active[0].embedder.ModelID()
+}
+
+// TaskType returns the primary's document task type
+func (f *FailoverEmbedder) TaskType() string {
+	return f.active[0].embedder.TaskType()
+}
+
+// MaxInputTokens returns the smallest input limit of the active providers
+func (f *FailoverEmbedder) MaxInputTokens() int {
+	limit := f.active[0].embedder.MaxInputTokens()
+	for _, member := range f.active[1:] {
+		limit = min(limit, member.embedder.MaxInputTokens())
+	}
+	return limit
+}
+
+// EmbedText embeds a document chunk for storage
+func (f *FailoverEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	var embedding []float32
+	err := f.do(ctx, func(textEmbedder Embedder) error {
+		var err error
+		embedding, err = textEmbedder.EmbedText(ctx, text)
+		return err
+	})
+	return embedding, err
+}
+
+// EmbedQuery embeds a search query to compare against stored chunks
+func (f *FailoverEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	var embedding []float32
+	err := f.do(ctx, func(textEmbedder Embedder) error {
+		var err error
+		embedding, err = textEmbedder.EmbedQuery(ctx, query)
+		return err
+	})
+	return embedding, err
+}
+
+// EmbedBatch embeds document chunks, in order, all with the same provider
+func (f *FailoverEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	var embeddings [][]float32
+	err := f.do(ctx, func(textEmbedder Embedder) error {
+		var err error
+		embeddings, err = textEmbedder.EmbedBatch(ctx, texts)
+		return err
+	})
+	return embeddings, err
+}
+
+// do runs call against the healthy providers in chain order, then against
+// those cooling down, until one succeeds. Errors caused by the request
+// itself are returned without failing over.
+func (f *FailoverEmbedder) do(ctx context.Context, call func(Embedder) error) error {
+	now := time.Now()
+	order := make([]failoverMember, 0, len(f.active))
+	var cooling []failoverMember
+	for _, member := range f.active {
+		if member.health.available(now) {
+			order = append(order, member)
+		} else {
+			cooling = append(cooling, member)
+		}
+	}
+	order = append(order, cooling...)
+
+	var lastErr error
+	for _, member := range order {
+		err := call(member.embedder)
+		if err == nil {
+			member.health.succeeded()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if requestError(err) {
+			return err
+		}
+		member.health.failed(err)
+		lastErr = err
+	}
+	if len(order) == 1 {
+		return lastErr
+	}
+	return fmt.Errorf("all %d embedding providers failed, last error: %w", len(order), lastErr)
+}
+
+// compatibleMembers returns the members whose vectors can be mixed with the
+// first one's
+func compatibleMembers(chain []failoverMember) []failoverMember {
+	active := []failoverMember{chain[0]}
+	for _, member := range chain[1:] {
+		if compatible(chain[0].embedder, member.embedder) {
+			active = append(active, member)
+		}
+	}
+	return active
+}
+
+// compatible reports whether two embedders produce interchangeable vectors.
+// Providers serving the same model are compatible, so only the model name
+// after the provider prefix is compared.
+func compatible(a, b Embedder) bool {
+	return modelName(a.ModelID()) == modelName(b.ModelID()) && a.TaskType() == b.TaskType() && a.Dimensions() == b.Dimensions()
+}
+
+// modelName strips the provider prefix from a model ID
+func modelName(modelID string) string {
+	if _, name, ok := strings.Cut(modelID, "/"); ok {
+		return name
+	}
+	return modelID
+}
+
+// requestError reports whether err rejects the request itself, which every
+// provider would refuse as well
+func requestError(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.invalidKey() {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500
+}
+
+// providerHealth tracks consecutive failures of one provider
+type providerHealth struct {
+	mu        sync.Mutex
+	name      string
+	failures  int
+	downUntil time.Time
+}
+
+// available reports whether the provider is out of its cooldown
+func (h *providerHealth) available(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !now.Before(h.downUntil)
+}
+
+func (h *providerHealth) failed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures++
+	cooldown := failoverCooldownMax
+	if h.failures < 8 {
+		cooldown = min(failoverCooldownMax, failoverCooldownBase<<(h.failures-1))
+	}
+	h.downUntil = time.Now().Add(cooldown)
+	utils.Zlog.Warn("Embedding provider failed, failing over",
+		zap.String("provider", h.name),
+		zap.Int("consecutiveFailures", h.failures),
+		zap.Duration("cooldown", cooldown),
+		zap.Error(err))
+}
+
+func (h *providerHealth) succeeded() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failures > 0 {
+		utils.Zlog.Info("Embedding provider recovered",
+			zap.String("provider", h.name),
+			zap.Int("failures", h.failures))
+	}
+	h.failures = 0
+	h.downUntil = time.Time{}
+}
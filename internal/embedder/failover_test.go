@@ -0,0 +1,150 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var errUnavailable = &apiError{StatusCode: http.StatusServiceUnavailable, Body: "overloaded"}
+
+func TestFailoverOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		primaryErr   error
+		fallbackErr  error
+		wantErr      bool
+		wantPrimary  int
+		wantFallback int
+	}{
+		{name: "primary healthy", wantPrimary: 1},
+		{name: "primary down", primaryErr: errUnavailable, wantPrimary: 1, wantFallback: 1},
+		{name: "primary key refused", primaryErr: &apiError{StatusCode: http.StatusUnauthorized}, wantPrimary: 1, wantFallback: 1},
+		{name: "rate limited", primaryErr: &apiError{StatusCode: http.StatusTooManyRequests}, wantPrimary: 1, wantFallback: 1},
+		{name: "request rejected", primaryErr: &apiError{StatusCode: http.StatusBadRequest, Body: "input too long"}, wantErr: true, wantPrimary: 1},
+		{name: "network error", primaryErr: errors.New("connection reset"), wantPrimary: 1, wantFallback: 1},
+		{name: "all down", primaryErr: errUnavailable, fallbackErr: errUnavailable, wantErr: true, wantPrimary: 1, wantFallback: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newStub(t, Settings{Dimensions: 8})
+			fallback := newStub(t, Settings{Dimensions: 8})
+			primary.err, fallback.err = tt.primaryErr, tt.fallbackErr
+
+			f, err := NewFailoverEmbedder([]FailoverProvider{
+				{Name: "primary", Embedder: primary},
+				{Name: "fallback", Embedder: fallback},
+			})
+			if err != nil {
+				t.Fatalf("NewFailoverEmbedder() error = %v", err)
+			}
+
+			_, err = f.EmbedBatch(context.Background(), []string{"hello world"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EmbedBatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if primary.calls != tt.wantPrimary || fallback.calls != tt.wantFallback {
+				t.Errorf("calls = (%d, %d), want (%d, %d)", primary.calls, fallback.calls, tt.wantPrimary, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestFailoverCooldown(t *testing.T) {
+	primary := newStub(t, Settings{Dimensions: 8})
+	fallback := newStub(t, Settings{Dimensions: 8})
+	f, err := NewFailoverEmbedder([]FailoverProvider{
+		{Name: "primary", Embedder: primary},
+		{Name: "fallback", Embedder: fallback},
+	})
+	if err != nil {
+		t.Fatalf("NewFailoverEmbedder() error = %v", err)
+	}
+	health := f.chain[0].health
+
+	steps := []struct {
+		name         string
+		primaryErr   error
+		expire       bool // end the primary's cooldown before the call
+		wantPrimary  int
+		wantFallback int
+		wantFailures int
+	}{
+		{name: "primary fails", primaryErr: errUnavailable, wantPrimary: 1, wantFallback: 1, wantFailures: 1},
+		{name: "primary skipped while cooling down", wantPrimary: 1, wantFallback: 2, wantFailures: 1},
+		{name: "primary retried after cooldown, fails again", primaryErr: errUnavailable, expire: true, wantPrimary: 2, wantFallback: 3, wantFailures: 2},
+		{name: "primary recovers", expire: true, wantPrimary: 3, wantFallback: 3},
+		{name: "primary used again", wantPrimary: 4, wantFallback: 3},
+	}
+
+	for _, step := range steps {
+		primary.err = step.primaryErr
+		if step.expire {
+			health.downUntil = time.Now().Add(-time.Second)
+		}
+		if _, err := f.EmbedText(context.Background(), "hello"); err != nil {
+			t.Fatalf("%s: EmbedText() error = %v", step.name, err)
+		}
+		if primary.calls != step.wantPrimary || fallback.calls != step.wantFallback {
+			t.Errorf("%s: calls = (%d, %d), want (%d, %d)", step.name, primary.calls, fallback.calls, step.wantPrimary, step.wantFallback)
+		}
+		if health.failures != step.wantFailures {
+			t.Errorf("%s: failures = %d, want %d", step.name, health.failures, step.wantFailures)
+		}
+	}
+}
+
+func TestFailoverCooldownBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: failoverCooldownBase},
+		{failures: 2, want: 2 * failoverCooldownBase},
+		{failures: 4, want: 8 * failoverCooldownBase},
+		{failures: 5, want: failoverCooldownMax},
+		{failures: 40, want: failoverCooldownMax},
+	}
+
+	for _, tt := range tests {
+		h := &providerHealth{name: "test"}
+		for range tt.failures {
+			h.failed(errUnavailable)
+		}
+		got := time.Until(h.downUntil)
+		if got > tt.want || got < tt.want-time.Second {
+			t.Errorf("after %d failures cooldown = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestFailoverCompatibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback Settings
+		wantErr  bool
+	}{
+		{name: "same model", fallback: Settings{Dimensions: 8}},
+		{name: "other model", fallback: Settings{Model: "hash-v2", Dimensions: 8}, wantErr: true},
+		{name: "other dimensions", fallback: Settings{Dimensions: 16}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFailoverEmbedder([]FailoverProvider{
+				{Name: "primary", Embedder: newStub(t, Settings{Dimensions: 8})},
+				{Name: "fallback", Embedder: newStub(t, tt.fallback)},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewFailoverEmbedder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := modelName("gemini/text-embedding-004"); got != "text-embedding-004" {
+		t.Errorf("modelName() = %q, want the name without the provider", got)
+	}
+}
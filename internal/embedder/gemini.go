@@ -4,171 +4,400 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
-	"sync"
-	"sync/atomic"
+	"strconv"
+	"strings"
 	"time"
 
+	"go.uber.org/zap"
+
 	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
 )
 
-// GeminiEmbedder handles embedding generation with rotating API keys
+// DefaultGeminiModel is used when no EMBEDDING_MODEL is configured
+const DefaultGeminiModel = "text-embedding-004"
+
+// geminiMaxBatch is the most requests batchEmbedContents accepts at once
+const geminiMaxBatch = 100
+
+// geminiMaxAttempts bounds the requests made for one call, across keys
+const geminiMaxAttempts = 6
+
+// geminiMaxInputTokens is the input limit of the Gemini embedding models
+const geminiMaxInputTokens = 2048
+
+// GeminiEmbedder handles embedding generation with rotating API keys. Each key
+// is rate limited on its own, rested when throttled and dropped when invalid.
 type GeminiEmbedder struct {
-	apiKeys     []string
+	keys        *keyPool
+	model       string
+	taskType    string // task type documents are embedded with
+	dimensions  int
 	client      *http.Client
 	baseURL     string
-	keyIndex    uint64        // atomic counter for round-robin key selection
-	rateLimiter chan struct{} // global rate limiter across all workers
+	rateLimiter chan struct{} // global concurrency limit across all workers
 }
 
-// NewGeminiEmbedder creates a new embedder with API keys
-func NewGeminiEmbedder(keys []string) (*GeminiEmbedder, error) {
+var _ Embedder = (*GeminiEmbedder)(nil)
+
+// Gemini task types. Documents can be embedded for retrieval, similarity,
+// classification or clustering; queries use the matching query-side type.
+const (
+	TaskRetrievalDocument  = "RETRIEVAL_DOCUMENT"
+	TaskRetrievalQuery     = "RETRIEVAL_QUERY"
+	TaskSemanticSimilarity = "SEMANTIC_SIMILARITY"
+	TaskClassification     = "CLASSIFICATION"
+	TaskClustering         = "CLUSTERING"
+)
+
+var geminiDocumentTaskTypes = map[string]bool{
+	TaskRetrievalDocument:  true,
+	TaskSemanticSimilarity: true,
+	TaskClassification:     true,
+	TaskClustering:         true,
+}
+
+// NewGeminiEmbedder creates a new embedder with API keys. Unset settings
+// select DefaultGeminiModel, TaskRetrievalDocument and DefaultDimensions;
+// limits apply to each key separately.
+func NewGeminiEmbedder(keys []string, settings Settings, limits RateLimits) (*GeminiEmbedder, error) {
 	if len(keys) == 0 {
 		return nil, fmt.Errorf("at least one API key is required")
 	}
-	maxConcurrentRequests := 5
 
-	return &GeminiEmbedder{
-		apiKeys:     keys,
+	g := &GeminiEmbedder{
+		keys:        newKeyPool(keys, limits),
+		model:       DefaultGeminiModel,
+		taskType:    TaskRetrievalDocument,
+		dimensions:  DefaultDimensions,
 		client:      &http.Client{Timeout: 30 * time.Second},
 		baseURL:     "https://generativelanguage.googleapis.com/v1beta/models",
-		keyIndex:    0,
 		rateLimiter: make(chan struct{}, maxConcurrentRequests),
-	}, nil
+	}
+	if err := g.apply(settings); err != nil {
+		return nil, err
+	}
+	return g, nil
 }
 
-// getNextKey returns the next API key using round-robin selection
-// Thread-safe: uses atomic operations to ensure fair distribution across goroutines
-func (g *GeminiEmbedder) getNextKey() string {
-	if len(g.apiKeys) == 1 {
-		return g.apiKeys[0]
+// WithSettings returns an embedder that shares this one's keys and limits but
+// uses settings where they are set
+func (g *GeminiEmbedder) WithSettings(settings Settings) (Embedder, error) {
+	variant := *g
+	if err := variant.apply(settings); err != nil {
+		return nil, err
 	}
-	// Atomically increment and get the next index
-	// This ensures thread-safe round-robin across all concurrent requests
-	idx := atomic.AddUint64(&g.keyIndex, 1)
-	// Use modulo to wrap around when we exceed the number of keys
-	return g.apiKeys[idx%uint64(len(g.apiKeys))]
+	return &variant, nil
 }
 
-// normalize normalizes a vector to unit length
-func normalize(vec []float32) []float32 {
-	if len(vec) == 0 {
-		return vec
+func (g *GeminiEmbedder) apply(settings Settings) error {
+	if settings.TaskType != "" && !geminiDocumentTaskTypes[settings.TaskType] {
+		return fmt.Errorf("unsupported Gemini task type %q", settings.TaskType)
 	}
-
-	norm := float32(0.0)
-	for _, v := range vec {
-		norm += v * v
+	if settings.Dimensions < 0 {
+		return fmt.Errorf("dimensions must be positive")
 	}
-	norm = float32(math.Sqrt(float64(norm)))
-
-	if norm == 0 || math.IsNaN(float64(norm)) || math.IsInf(float64(norm), 0) {
-		return vec
+	if settings.Model != "" {
+		g.model = settings.Model
 	}
-
-	normalized := make([]float32, len(vec))
-	for i, v := range vec {
-		normalized[i] = v / norm
+	if settings.TaskType != "" {
+		g.taskType = settings.TaskType
 	}
-	return normalized
+	if settings.Dimensions > 0 {
+		g.dimensions = settings.Dimensions
+	}
+	return nil
+}
+
+// Dimensions returns the configured output dimensionality
+func (g *GeminiEmbedder) Dimensions() int {
+	return g.dimensions
+}
+
+// ModelID returns "gemini/<model>"
+func (g *GeminiEmbedder) ModelID() string {
+	return ProviderGemini + "/" + g.model
+}
+
+// TaskType returns the task type documents are embedded with
+func (g *GeminiEmbedder) TaskType() string {
+	return g.taskType
 }
 
+// MaxInputTokens returns the input limit of the Gemini embedding models
+func (g *GeminiEmbedder) MaxInputTokens() int {
+	return geminiMaxInputTokens
+}
+
+// EmbedText embeds a document chunk for storage
 func (g *GeminiEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
-	if text == "" {
-		return nil, fmt.Errorf("text cannot be empty")
+	return g.embed(ctx, text, g.taskType)
+}
+
+// EmbedQuery embeds a search query to compare against stored chunks. Only
+// retrieval has a separate query task type; the symmetric tasks embed queries
+// like documents.
+func (g *GeminiEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	if g.taskType == TaskRetrievalDocument {
+		return g.embed(ctx, query, TaskRetrievalQuery)
 	}
+	return g.embed(ctx, query, g.taskType)
+}
 
-	select {
-	case g.rateLimiter <- struct{}{}:
-		defer func() { <-g.rateLimiter }()
-	case <-ctx.Done():
-		return nil, ctx.Err()
+func (g *GeminiEmbedder) embed(ctx context.Context, text, taskType string) ([]float32, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
 	}
 
 	reqBody := types.EmbeddingRequest{
-		Model: "text-embedding-004",
+		Model: g.model,
 		Content: types.EmbeddingContent{
 			Parts: []types.Part{
 				{Text: text},
 			},
 		},
-		TaskType:             "RETRIEVAL_DOCUMENT",
-		OutputDimensionality: 768,
+		TaskType:             taskType,
+		OutputDimensionality: g.dimensions,
+	}
+
+	var embeddingResp types.EmbeddingResponse
+	if err := g.call(ctx, "embedContent", reqBody, utils.DefaultTokenizer.CountTokens(text), &embeddingResp); err != nil {
+		return nil, err
+	}
+
+	values, err := fitDimensions(embeddingResp.Embedding.Values, g.dimensions)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := normalize(values)
+	return normalized, nil
+}
+
+// EmbedBatch embeds document chunks with batchEmbedContents, up to
+// geminiMaxBatch texts per request
+func (g *GeminiEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("no texts provided")
+	}
+	for i, text := range texts {
+		if text == "" {
+			return nil, fmt.Errorf("text at index %d cannot be empty", i)
+		}
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += geminiMaxBatch {
+		end := start + geminiMaxBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := g.embedBatch(ctx, texts[start:end], g.taskType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed texts %d-%d: %w", start, end-1, err)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+func (g *GeminiEmbedder) embedBatch(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	reqBody := types.BatchEmbeddingRequest{Requests: make([]types.EmbeddingRequest, len(texts))}
+	tokens := 0
+	for i, text := range texts {
+		tokens += utils.DefaultTokenizer.CountTokens(text)
+		reqBody.Requests[i] = types.EmbeddingRequest{
+			Model: "models/" + g.model,
+			Content: types.EmbeddingContent{
+				Parts: []types.Part{
+					{Text: text},
+				},
+			},
+			TaskType:             taskType,
+			OutputDimensionality: g.dimensions,
+		}
+	}
+
+	var batchResp types.BatchEmbeddingResponse
+	if err := g.call(ctx, "batchEmbedContents", reqBody, tokens, &batchResp); err != nil {
+		return nil, err
 	}
 
+	if len(batchResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(batchResp.Embeddings))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i, embedding := range batchResp.Embeddings {
+		values, err := fitDimensions(embedding.Values, g.dimensions)
+		if err != nil {
+			return nil, fmt.Errorf("embedding %d: %w", i, err)
+		}
+		embeddings[i] = normalize(values)
+	}
+	return embeddings, nil
+}
+
+// call sends reqBody to the model's method with the next available key and
+// decodes the response into out. Throttled keys are rested and invalid keys
+// disabled before retrying with another key; server and network errors are
+// retried with jittered exponential backoff.
+func (g *GeminiEmbedder) call(ctx context.Context, method string, reqBody any, tokens int, out any) error {
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	apiKey := g.getNextKey()
-	url := fmt.Sprintf("%s/text-embedding-004:embedContent?key=%s", g.baseURL, apiKey)
+	var lastErr error
+	for attempt := 0; attempt < geminiMaxAttempts; attempt++ {
+		key, err := g.keys.acquire(ctx, tokens)
+		if err != nil {
+			if errors.Is(err, ErrNoUsableKeys) && lastErr != nil {
+				return fmt.Errorf("%w, last error: %v", err, lastErr)
+			}
+			return err
+		}
+
+		err = g.send(ctx, method, key.value, jsonBody, out)
+		if err == nil {
+			g.keys.succeeded(key)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		lastErr = err
+
+		var apiErr *apiError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+			cooldown := g.keys.throttled(key, apiErr.RetryAfter)
+			utils.Zlog.Warn("Gemini API key throttled, cooling down",
+				zap.String("key", fingerprint(key.value)),
+				zap.Duration("cooldown", cooldown))
+
+		case errors.As(err, &apiErr) && apiErr.invalidKey():
+			g.keys.disable(key)
+			utils.Zlog.Error("Gemini API key rejected, disabling it",
+				zap.String("key", fingerprint(key.value)),
+				zap.Int("status", apiErr.StatusCode),
+				zap.Int("usableKeys", g.keys.usable()))
+
+		case errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError:
+			return err
+
+		default:
+			// Server or network error, not the key's fault
+			timer := time.NewTimer(max(backoff(attempt), apiErrRetryAfter(apiErr)))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", geminiMaxAttempts, lastErr)
+}
+
+// send makes one request and decodes a successful response into out
+func (g *GeminiEmbedder) send(ctx context.Context, method, apiKey string, jsonBody []byte, out any) error {
+	select {
+	case g.rateLimiter <- struct{}{}:
+		defer func() { <-g.rateLimiter }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	url := fmt.Sprintf("%s/%s:%s?key=%s", g.baseURL, g.model, method, apiKey)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := g.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var embeddingResp types.EmbeddingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return &apiError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After"), body),
+		}
 	}
 
-	if len(embeddingResp.Embedding.Values) == 0 {
-		return nil, fmt.Errorf("no embeddings returned from API")
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
 	}
+	return nil
+}
 
-	if len(embeddingResp.Embedding.Values) != 768 {
-		return nil, fmt.Errorf("expected 768 dimensions, got %d", len(embeddingResp.Embedding.Values))
-	}
+// apiError is a non-200 response from a provider API
+type apiError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // zero when the server gave no hint
+}
 
-	normalized := normalize(embeddingResp.Embedding.Values)
-	return normalized, nil
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
 }
 
-func (g *GeminiEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
-	if len(texts) == 0 {
-		return nil, fmt.Errorf("no texts provided")
+// invalidKey reports whether the key itself was refused, as opposed to the request
+func (e *apiError) invalidKey() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	case http.StatusBadRequest:
+		return strings.Contains(e.Body, "API_KEY_INVALID")
+	default:
+		return false
 	}
+}
 
-	embeddings := make([][]float32, len(texts))
-	errors := make([]error, len(texts))
-
-	var wg sync.WaitGroup
-
-	for i, text := range texts {
-		wg.Add(1)
-		go func(idx int, txt string) {
-			defer wg.Done()
-			embedding, err := g.EmbedText(ctx, txt)
-			embeddings[idx] = embedding
-			errors[idx] = err
-		}(i, text)
+func apiErrRetryAfter(err *apiError) time.Duration {
+	if err == nil {
+		return 0
 	}
+	return err.RetryAfter
+}
 
-	wg.Wait()
-
-	for i, err := range errors {
-		if err != nil {
-			return nil, fmt.Errorf("failed to embed text at index %d: %w", i, err)
+// retryAfter reads the Retry-After header (seconds or HTTP date), falling
+// back to the retryDelay of a google.rpc.RetryInfo detail in the body
+func retryAfter(header string, body []byte) time.Duration {
+	if header != "" {
+		if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(header); err == nil {
+			return time.Until(at)
 		}
 	}
 
-	return embeddings, nil
+	var errBody struct {
+		Error struct {
+			Details []struct {
+				RetryDelay string `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errBody) == nil {
+		for _, detail := range errBody.Error.Details {
+			if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay > 0 {
+				return delay
+			}
+		}
+	}
+	return 0
 }
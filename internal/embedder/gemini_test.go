@@ -0,0 +1,37 @@
+package embedder
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   time.Duration
+	}{
+		{name: "seconds header", header: "5", want: 5 * time.Second},
+		{name: "date header", header: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), want: time.Minute},
+		{name: "header wins over body", header: "3", body: `{"error":{"details":[{"retryDelay":"9s"}]}}`, want: 3 * time.Second},
+		{name: "zero header falls back to body", header: "0", body: `{"error":{"details":[{"retryDelay":"9s"}]}}`, want: 9 * time.Second},
+		{name: "invalid header falls back to body", header: "soon", body: `{"error":{"details":[{"retryDelay":"1.5s"}]}}`, want: 1500 * time.Millisecond},
+		{name: "first detail with a delay", body: `{"error":{"details":[{"@type":"QuotaFailure"},{"retryDelay":"12s"}]}}`, want: 12 * time.Second},
+		{name: "negative delay", body: `{"error":{"details":[{"retryDelay":"-1s"}]}}`},
+		{name: "body without a delay", body: `{"error":{"message":"quota exceeded"}}`},
+		{name: "not json", body: "Too Many Requests"},
+		{name: "nothing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := retryAfter(tt.header, []byte(tt.body))
+			// HTTP dates have second precision and are measured from now
+			if got > tt.want || got < tt.want-2*time.Second || (tt.want == 0 && got != 0) {
+				t.Errorf("retryAfter(%q, %q) = %v, want %v", tt.header, tt.body, got, tt.want)
+			}
+		})
+	}
+}
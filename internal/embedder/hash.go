@@ -0,0 +1,153 @@
+package embedder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// hashDefaultModel names the vectors HashEmbedder produces when no model is
+// configured; other model names seed different vectors
+const hashDefaultModel = "hash-v1"
+
+// HashEmbedder derives vectors from the text alone, without a network or
+// credentials, for tests and local development. Each word adds a vector
+// seeded from its hash and the sum is normalized, so the same text always
+// gets the same vector and texts sharing words score as similar.
+type HashEmbedder struct {
+	model      string
+	dimensions int
+}
+
+var _ Embedder = (*HashEmbedder)(nil)
+
+// NewHashEmbedder creates a deterministic embedder. The model defaults to
+// hash-v1 and zero dimensions select DefaultDimensions. Task types are not
+// supported.
+func NewHashEmbedder(settings Settings) (*HashEmbedder, error) {
+	h := &HashEmbedder{
+		model:      hashDefaultModel,
+		dimensions: DefaultDimensions,
+	}
+	if err := h.apply(settings); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WithSettings returns an embedder using settings where they are set
+func (h *HashEmbedder) WithSettings(settings Settings) (Embedder, error) {
+	variant := *h
+	if err := variant.apply(settings); err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+func (h *HashEmbedder) apply(settings Settings) error {
+	if settings.TaskType != "" {
+		return fmt.Errorf("task types are not supported by the hash embedder")
+	}
+	if settings.Dimensions < 0 {
+		return fmt.Errorf("dimensions must be positive")
+	}
+	if settings.Model != "" {
+		h.model = settings.Model
+	}
+	if settings.Dimensions > 0 {
+		h.dimensions = settings.Dimensions
+	}
+	return nil
+}
+
+// Dimensions returns the configured output dimensionality
+func (h *HashEmbedder) Dimensions() int {
+	return h.dimensions
+}
+
+// ModelID returns "hash/<model>"
+func (h *HashEmbedder) ModelID() string {
+	return ProviderHash + "/" + h.model
+}
+
+// TaskType is always empty
+func (h *HashEmbedder) TaskType() string {
+	return ""
+}
+
+// MaxInputTokens mirrors the Gemini limit so local runs split chunks the
+// same way
+func (h *HashEmbedder) MaxInputTokens() int {
+	return geminiMaxInputTokens
+}
+
+// EmbedText embeds a document chunk for storage
+func (h *HashEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.embed(text), nil
+}
+
+// EmbedQuery embeds a search query like a document, so a query matching a
+// chunk's text finds it with a similarity of one
+func (h *HashEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return h.EmbedText(ctx, query)
+}
+
+// EmbedBatch embeds document chunks, in order
+func (h *HashEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("no texts provided")
+	}
+	for i, text := range texts {
+		if text == "" {
+			return nil, fmt.Errorf("text at index %d cannot be empty", i)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = h.embed(text)
+	}
+	return embeddings, nil
+}
+
+// embed sums a pseudo-random vector per lowercased word, each seeded from the
+// model and the word. Text without letters or digits is seeded as a whole.
+func (h *HashEmbedder) embed(text string) []float32 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		words = []string{text}
+	}
+
+	vec := make([]float32, h.dimensions)
+	for _, word := range words {
+		seed := sha256.Sum256([]byte(h.model + "|" + word))
+		state := binary.LittleEndian.Uint64(seed[:8])
+		for i := range vec {
+			vec[i] += float32(float64(splitMix64(&state)>>11)/(1<<53)*2 - 1)
+		}
+	}
+	return normalize(vec)
+}
+
+// splitMix64 advances state and returns its next pseudo-random value
+func splitMix64(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
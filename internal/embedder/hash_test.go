@@ -0,0 +1,167 @@
+package embedder
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestHashEmbedderDeterministic(t *testing.T) {
+	tests := []struct {
+		name       string
+		settings   Settings
+		text       string
+		dimensions int
+	}{
+		{name: "defaults", text: "The quick brown fox", dimensions: DefaultDimensions},
+		{name: "custom dimensions", settings: Settings{Dimensions: 16}, text: "The quick brown fox", dimensions: 16},
+		{name: "custom model", settings: Settings{Model: "hash-v2"}, text: "jumps over the lazy dog", dimensions: DefaultDimensions},
+		{name: "no words", settings: Settings{Dimensions: 8}, text: "?!", dimensions: 8},
+		{name: "non-latin", settings: Settings{Dimensions: 32}, text: "日本語のテキスト", dimensions: 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewHashEmbedder(tt.settings)
+			if err != nil {
+				t.Fatalf("NewHashEmbedder() error = %v", err)
+			}
+			first, err := h.EmbedText(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("EmbedText() error = %v", err)
+			}
+			again, err := h.EmbedText(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("EmbedText() error = %v", err)
+			}
+
+			if len(first) != tt.dimensions {
+				t.Fatalf("len = %d, want %d", len(first), tt.dimensions)
+			}
+			for i := range first {
+				if first[i] != again[i] {
+					t.Fatalf("vectors differ at %d: %v != %v", i, first[i], again[i])
+				}
+			}
+			if norm := vectorNorm(first); math.Abs(norm-1) > 1e-5 {
+				t.Errorf("norm = %v, want 1", norm)
+			}
+		})
+	}
+}
+
+func TestHashEmbedderSimilarity(t *testing.T) {
+	h, err := NewHashEmbedder(Settings{})
+	if err != nil {
+		t.Fatalf("NewHashEmbedder() error = %v", err)
+	}
+	other, err := h.WithSettings(Settings{Model: "hash-v2"})
+	if err != nil {
+		t.Fatalf("WithSettings() error = %v", err)
+	}
+
+	ctx := context.Background()
+	embed := func(e Embedder, text string) []float32 {
+		t.Helper()
+		vec, err := e.EmbedText(ctx, text)
+		if err != nil {
+			t.Fatalf("EmbedText(%q) error = %v", text, err)
+		}
+		return vec
+	}
+
+	base := embed(h, "refund policy for annual plans")
+	tests := []struct {
+		name string
+		vec  []float32
+		min  float64
+		max  float64
+	}{
+		{name: "same words, other case", vec: embed(h, "Refund Policy for Annual Plans"), min: 0.9999, max: 1.0001},
+		{name: "query matches document", vec: mustQuery(t, h, "refund policy for annual plans"), min: 0.9999, max: 1.0001},
+		{name: "shared words", vec: embed(h, "refund policy for monthly plans"), min: 0.5, max: 0.9999},
+		{name: "no shared words", vec: embed(h, "shipping takes two weeks"), min: -0.3, max: 0.3},
+		{name: "other model", vec: embed(other, "refund policy for annual plans"), min: -0.3, max: 0.3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sim := dot(base, tt.vec); sim < tt.min || sim > tt.max {
+				t.Errorf("similarity = %v, want between %v and %v", sim, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestHashEmbedderSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+		modelID  string
+	}{
+		{name: "defaults", modelID: "hash/hash-v1"},
+		{name: "model", settings: Settings{Model: "custom"}, modelID: "hash/custom"},
+		{name: "task type", settings: Settings{TaskType: "RETRIEVAL_DOCUMENT"}, wantErr: true},
+		{name: "negative dimensions", settings: Settings{Dimensions: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewHashEmbedder(tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewHashEmbedder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && h.ModelID() != tt.modelID {
+				t.Errorf("ModelID() = %q, want %q", h.ModelID(), tt.modelID)
+			}
+		})
+	}
+}
+
+func TestHashEmbedderBatchMatchesText(t *testing.T) {
+	h, err := NewHashEmbedder(Settings{Dimensions: 24})
+	if err != nil {
+		t.Fatalf("NewHashEmbedder() error = %v", err)
+	}
+	texts := []string{"first chunk", "second chunk", "first chunk"}
+
+	batch, err := h.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	for i, text := range texts {
+		single, err := h.EmbedText(context.Background(), text)
+		if err != nil {
+			t.Fatalf("EmbedText() error = %v", err)
+		}
+		if sim := dot(batch[i], single); math.Abs(sim-1) > 1e-5 {
+			t.Errorf("batch[%d] differs from EmbedText, similarity %v", i, sim)
+		}
+	}
+
+	if _, err := h.EmbedBatch(context.Background(), []string{"ok", ""}); err == nil {
+		t.Error("EmbedBatch() with an empty text succeeded")
+	}
+}
+
+func mustQuery(t *testing.T, e Embedder, query string) []float32 {
+	t.Helper()
+	vec, err := e.EmbedQuery(context.Background(), query)
+	if err != nil {
+		t.Fatalf("EmbedQuery(%q) error = %v", query, err)
+	}
+	return vec
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func vectorNorm(vec []float32) float64 {
+	return math.Sqrt(dot(vec, vec))
+}
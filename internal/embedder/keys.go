@@ -0,0 +1,204 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrNoUsableKeys is returned when every API key has been disabled
+var ErrNoUsableKeys = errors.New("no usable API keys")
+
+// RateLimits caps the traffic sent with each API key; zero means unlimited
+type RateLimits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// tokenBucket refills continuously up to one minute's allowance. A nil bucket
+// never limits.
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// wait returns how long until n tokens are available. Requests larger than
+// the bucket only wait for it to fill.
+func (b *tokenBucket) wait(now time.Time, n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	n = min(n, b.capacity)
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b == nil {
+		return
+	}
+	b.tokens -= min(n, b.capacity)
+}
+
+// apiKey tracks the limits and health of one key
+type apiKey struct {
+	value         string
+	requests      *tokenBucket
+	tokens        *tokenBucket
+	cooldownUntil time.Time
+	disabled      bool
+	throttles     int // consecutive throttled responses, drives the cooldown length
+}
+
+// keyPool hands out keys round-robin, skipping keys that are disabled,
+// cooling down after a 429, or out of budget for the request
+type keyPool struct {
+	mu   sync.Mutex
+	keys []*apiKey
+	next int
+}
+
+func newKeyPool(values []string, limits RateLimits) *keyPool {
+	pool := &keyPool{}
+	for _, value := range values {
+		pool.keys = append(pool.keys, &apiKey{
+			value:    value,
+			requests: newTokenBucket(limits.RequestsPerMinute),
+			tokens:   newTokenBucket(limits.TokensPerMinute),
+		})
+	}
+	return pool
+}
+
+// acquire waits for a key that can send one request of the given token
+// estimate and charges the request to it
+func (p *keyPool) acquire(ctx context.Context, tokens int) (*apiKey, error) {
+	for {
+		key, wait, err := p.tryAcquire(time.Now(), float64(tokens))
+		if err != nil || key != nil {
+			return key, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAcquire returns a usable key, or how long until the first one frees up
+func (p *keyPool) tryAcquire(now time.Time, tokens float64) (*apiKey, time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	shortest := time.Duration(-1)
+	for i := range p.keys {
+		idx := (p.next + i) % len(p.keys)
+		key := p.keys[idx]
+		if key.disabled {
+			continue
+		}
+
+		wait := max(key.cooldownUntil.Sub(now), key.requests.wait(now, 1), key.tokens.wait(now, tokens))
+		if wait <= 0 {
+			key.requests.take(1)
+			key.tokens.take(tokens)
+			p.next = idx + 1
+			return key, 0, nil
+		}
+		if shortest < 0 || wait < shortest {
+			shortest = wait
+		}
+	}
+
+	if shortest < 0 {
+		return nil, 0, ErrNoUsableKeys
+	}
+	return nil, shortest, nil
+}
+
+// succeeded clears the key's throttle streak
+func (p *keyPool) succeeded(key *apiKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key.throttles = 0
+}
+
+// throttled rests the key for retryAfter, or an exponentially growing
+// cooldown when the server gave no hint, and returns the cooldown applied
+func (p *keyPool) throttled(key *apiKey, retryAfter time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key.throttles++
+	cooldown := retryAfter
+	if cooldown <= 0 {
+		cooldown = backoff(key.throttles - 1)
+	}
+	key.cooldownUntil = time.Now().Add(cooldown)
+	return cooldown
+}
+
+// disable stops handing out a key the API rejected as invalid
+func (p *keyPool) disable(key *apiKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key.disabled = true
+}
+
+// usable counts the keys that have not been disabled
+func (p *keyPool) usable() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, key := range p.keys {
+		if !key.disabled {
+			n++
+		}
+	}
+	return n
+}
+
+const (
+	backoffBase = 500 * time.Millisecond
+	backoffMax  = 60 * time.Second
+)
+
+// backoff returns a full-jitter exponential delay for the given retry attempt
+func backoff(attempt int) time.Duration {
+	ceiling := backoffMax
+	if attempt < 16 {
+		ceiling = min(backoffMax, backoffBase<<attempt)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// fingerprint identifies a key in logs without revealing it
+func fingerprint(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "…" + key[len(key)-4:]
+}
@@ -0,0 +1,236 @@
+package embedder
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	type step struct {
+		after time.Duration // since the previous step
+		n     float64
+		want  time.Duration
+	}
+
+	tests := []struct {
+		name      string
+		perMinute int
+		steps     []step
+	}{
+		{
+			name:      "unlimited",
+			perMinute: 0,
+			steps:     []step{{n: 1e9}, {n: 1e9}},
+		},
+		{
+			name:      "full bucket",
+			perMinute: 60,
+			steps:     []step{{n: 30}, {n: 30}, {n: 1, want: time.Second}},
+		},
+		{
+			name:      "refills with time",
+			perMinute: 60,
+			steps:     []step{{n: 60}, {after: 30 * time.Second, n: 40, want: 10 * time.Second}, {after: 10 * time.Second, n: 40}},
+		},
+		{
+			name:      "refill capped at a minute's allowance",
+			perMinute: 60,
+			steps:     []step{{n: 60}, {after: time.Hour, n: 60}, {n: 1, want: time.Second}},
+		},
+		{
+			name:      "oversize request waits for a full bucket",
+			perMinute: 60,
+			steps:     []step{{n: 1}, {n: 100, want: time.Second}, {after: time.Second, n: 100}, {n: 1, want: time.Second}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.perMinute)
+			now := time.Unix(1700000000, 0)
+			if b != nil {
+				b.last = now
+			}
+
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				got := b.wait(now, s.n)
+				if got != s.want {
+					t.Fatalf("step %d: wait(%v) = %v, want %v", i, s.n, got, s.want)
+				}
+				if got == 0 {
+					b.take(s.n)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyPoolAcquire(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   RateLimits
+		tokens   float64
+		setup    func(keys map[string]*apiKey, now time.Time)
+		want     []string // keys handed out in order
+		wantWait time.Duration
+		wantErr  error
+	}{
+		{
+			name: "round robin",
+			want: []string{"a", "b", "c", "a", "b"},
+		},
+		{
+			name: "skips cooling keys",
+			setup: func(keys map[string]*apiKey, now time.Time) {
+				keys["b"].cooldownUntil = now.Add(time.Minute)
+			},
+			want: []string{"a", "c", "a", "c"},
+		},
+		{
+			name: "skips disabled keys",
+			setup: func(keys map[string]*apiKey, now time.Time) {
+				keys["a"].disabled = true
+			},
+			want: []string{"b", "c", "b"},
+		},
+		{
+			name: "uses a key whose cooldown ended",
+			setup: func(keys map[string]*apiKey, now time.Time) {
+				keys["a"].cooldownUntil = now.Add(-time.Second)
+				keys["b"].disabled = true
+			},
+			want: []string{"a", "c", "a"},
+		},
+		{
+			name: "waits for the first cooldown to end",
+			setup: func(keys map[string]*apiKey, now time.Time) {
+				keys["a"].cooldownUntil = now.Add(5 * time.Second)
+				keys["b"].cooldownUntil = now.Add(2 * time.Second)
+				keys["c"].disabled = true
+			},
+			wantWait: 2 * time.Second,
+		},
+		{
+			name:     "waits for request budget",
+			limits:   RateLimits{RequestsPerMinute: 1},
+			want:     []string{"a", "b", "c"},
+			wantWait: time.Minute,
+		},
+		{
+			name:     "waits for token budget",
+			limits:   RateLimits{TokensPerMinute: 120},
+			tokens:   90,
+			want:     []string{"a", "b", "c"},
+			wantWait: 30 * time.Second,
+		},
+		{
+			name: "all keys disabled",
+			setup: func(keys map[string]*apiKey, now time.Time) {
+				for _, key := range keys {
+					key.disabled = true
+				}
+			},
+			wantErr: ErrNoUsableKeys,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newKeyPool([]string{"a", "b", "c"}, tt.limits)
+			now := time.Now()
+			keys := make(map[string]*apiKey)
+			for _, key := range pool.keys {
+				keys[key.value] = key
+				if key.requests != nil {
+					key.requests.last = now
+				}
+				if key.tokens != nil {
+					key.tokens.last = now
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(keys, now)
+			}
+
+			for i, want := range tt.want {
+				key, wait, err := pool.tryAcquire(now, tt.tokens)
+				if err != nil || key == nil {
+					t.Fatalf("call %d: tryAcquire() = %v, wait %v, error %v, want key %q", i, key, wait, err, want)
+				}
+				if key.value != want {
+					t.Errorf("call %d: key = %q, want %q", i, key.value, want)
+				}
+			}
+			if tt.wantWait == 0 && tt.wantErr == nil {
+				return
+			}
+
+			key, wait, err := pool.tryAcquire(now, tt.tokens)
+			if key != nil {
+				t.Fatalf("tryAcquire() = key %q, want none", key.value)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("tryAcquire() error = %v, want %v", err, tt.wantErr)
+			}
+			if wait != tt.wantWait {
+				t.Errorf("tryAcquire() wait = %v, want %v", wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestKeyPoolThrottled(t *testing.T) {
+	pool := newKeyPool([]string{"a"}, RateLimits{})
+	key := pool.keys[0]
+
+	steps := []struct {
+		name       string
+		retryAfter time.Duration
+		succeed    bool // the key succeeds before the throttle
+		ceiling    time.Duration
+	}{
+		{name: "server hint", retryAfter: 7 * time.Second, ceiling: 7 * time.Second},
+		{name: "backoff grows with the streak", ceiling: 2 * backoffBase},
+		{name: "backoff grows again", ceiling: 4 * backoffBase},
+		{name: "success resets the streak", succeed: true, ceiling: backoffBase},
+	}
+
+	for _, step := range steps {
+		if step.succeed {
+			pool.succeeded(key)
+		}
+		before := time.Now()
+		cooldown := pool.throttled(key, step.retryAfter)
+		if cooldown <= 0 || cooldown > step.ceiling {
+			t.Errorf("%s: cooldown = %v, want up to %v", step.name, cooldown, step.ceiling)
+		}
+		if step.retryAfter > 0 && cooldown != step.retryAfter {
+			t.Errorf("%s: cooldown = %v, want the server's %v", step.name, cooldown, step.retryAfter)
+		}
+		if key.cooldownUntil.Before(before.Add(cooldown)) {
+			t.Errorf("%s: cooling until %v, want at least %v", step.name, key.cooldownUntil, before.Add(cooldown))
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 0, ceiling: backoffBase},
+		{attempt: 3, ceiling: 8 * backoffBase},
+		{attempt: 7, ceiling: backoffMax},
+		{attempt: 64, ceiling: backoffMax},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			if got := backoff(tt.attempt); got <= 0 || got > tt.ceiling {
+				t.Fatalf("backoff(%d) = %v, want in (0, %v]", tt.attempt, got, tt.ceiling)
+			}
+		}
+	}
+}
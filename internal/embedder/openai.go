@@ -0,0 +1,225 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+// openAIMaxBatch is the most inputs sent in one request; OpenAI accepts 2048
+// but local servers are often configured far lower
+const openAIMaxBatch = 64
+
+// openAIMaxInputTokens is the input limit of OpenAI's embedding models
+const openAIMaxInputTokens = 8191
+
+// openAIResizablePrefix marks the models that accept a dimensions parameter;
+// older OpenAI models and most self-hosted servers reject it
+const openAIResizablePrefix = "text-embedding-3"
+
+// OpenAIEmbedder calls the /embeddings endpoint of an OpenAI-compatible API,
+// which covers OpenAI itself and self-hosted servers such as Ollama or
+// llama.cpp
+type OpenAIEmbedder struct {
+	baseURL     string
+	apiKey      string
+	model       string
+	dimensions  int
+	resize      bool // dimensions were configured rather than defaulted
+	client      *http.Client
+	rateLimiter chan struct{} // global rate limiter across all workers
+}
+
+var _ Embedder = (*OpenAIEmbedder)(nil)
+
+// NewOpenAIEmbedder creates an embedder for baseURL, e.g.
+// https://api.openai.com/v1 or http://localhost:11434/v1. apiKey may be empty
+// for local servers. The model is required. Dimensions are requested from
+// models that can shorten their vectors and are otherwise the size the model
+// is expected to return, DefaultDimensions when zero. The API has no task
+// types.
+func NewOpenAIEmbedder(baseURL, apiKey string, settings Settings) (*OpenAIEmbedder, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+	if settings.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	o := &OpenAIEmbedder{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		apiKey:      apiKey,
+		dimensions:  DefaultDimensions,
+		client:      &http.Client{Timeout: 60 * time.Second},
+		rateLimiter: make(chan struct{}, maxConcurrentRequests),
+	}
+	if err := o.apply(settings); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WithSettings returns an embedder that shares this one's client and limits
+// but uses settings where they are set
+func (o *OpenAIEmbedder) WithSettings(settings Settings) (Embedder, error) {
+	variant := *o
+	if err := variant.apply(settings); err != nil {
+		return nil, err
+	}
+	return &variant, nil
+}
+
+func (o *OpenAIEmbedder) apply(settings Settings) error {
+	if settings.TaskType != "" {
+		return fmt.Errorf("task types are not supported by OpenAI-compatible embedders")
+	}
+	if settings.Dimensions < 0 {
+		return fmt.Errorf("dimensions must be positive")
+	}
+	if settings.Model != "" {
+		o.model = settings.Model
+	}
+	if settings.Dimensions > 0 {
+		o.dimensions = settings.Dimensions
+		o.resize = true
+	}
+	return nil
+}
+
+// Dimensions returns the configured output dimensionality
+func (o *OpenAIEmbedder) Dimensions() int {
+	return o.dimensions
+}
+
+// ModelID returns "openai/<model>"
+func (o *OpenAIEmbedder) ModelID() string {
+	return ProviderOpenAI + "/" + o.model
+}
+
+// TaskType is always empty; the API does not distinguish tasks
+func (o *OpenAIEmbedder) TaskType() string {
+	return ""
+}
+
+// MaxInputTokens returns the input limit of OpenAI's embedding models
+func (o *OpenAIEmbedder) MaxInputTokens() int {
+	return openAIMaxInputTokens
+}
+
+// EmbedText embeds a document chunk for storage
+func (o *OpenAIEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+	embeddings, err := o.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// EmbedQuery embeds a search query; the API does not distinguish queries from
+// documents
+func (o *OpenAIEmbedder) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	return o.EmbedText(ctx, query)
+}
+
+// EmbedBatch embeds document chunks, several per request
+func (o *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("no texts provided")
+	}
+	for i, text := range texts {
+		if text == "" {
+			return nil, fmt.Errorf("text at index %d cannot be empty", i)
+		}
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIMaxBatch {
+		end := start + openAIMaxBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := o.embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed texts %d-%d: %w", start, end-1, err)
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+func (o *OpenAIEmbedder) embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	select {
+	case o.rateLimiter <- struct{}{}:
+		defer func() { <-o.rateLimiter }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	body := types.OpenAIEmbeddingRequest{
+		Model:          o.model,
+		Input:          inputs,
+		EncodingFormat: "float",
+	}
+	if o.resize && strings.HasPrefix(o.model, openAIResizablePrefix) {
+		body.Dimensions = o.dimensions
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/embeddings", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var embeddingResp types.OpenAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(embeddingResp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(embeddingResp.Data))
+	}
+
+	embeddings := make([][]float32, len(inputs))
+	for _, item := range embeddingResp.Data {
+		if item.Index < 0 || item.Index >= len(inputs) || embeddings[item.Index] != nil {
+			return nil, fmt.Errorf("unexpected embedding index %d in response", item.Index)
+		}
+		// Cutting a vector down only works for Matryoshka models, which are
+		// asked for the right size instead
+		if len(item.Embedding) != o.dimensions {
+			return nil, fmt.Errorf("model %s returned %d dimensions, expected %d; set the dimensions to the model's size",
+				o.model, len(item.Embedding), o.dimensions)
+		}
+		embeddings[item.Index] = normalize(item.Embedding)
+	}
+	return embeddings, nil
+}
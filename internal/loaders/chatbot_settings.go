@@ -0,0 +1,61 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetChatbotEmbeddingSettings returns the chatbot's embedding overrides, or nil
+// when it uses the global settings
+func (c *PostgresClient) GetChatbotEmbeddingSettings(ctx context.Context, chatbotID string) (*types.ChatbotEmbeddingSettings, error) {
+	settings := &types.ChatbotEmbeddingSettings{ChatbotID: chatbotID}
+	err := c.pool.QueryRow(ctx, `
+		SELECT COALESCE(model, ''), COALESCE(task_type, ''), COALESCE(dimensions, 0), updated_at
+		FROM chatbot_embedding_settings
+		WHERE chatbot_id = $1
+	`, chatbotID).Scan(&settings.Model, &settings.TaskType, &settings.Dimensions, &settings.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embedding settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpsertChatbotEmbeddingSettings stores the chatbot's embedding overrides,
+// replacing any previous ones
+func (c *PostgresClient) UpsertChatbotEmbeddingSettings(ctx context.Context, settings types.ChatbotEmbeddingSettings) (*types.ChatbotEmbeddingSettings, error) {
+	stored := settings
+	err := c.pool.QueryRow(ctx, `
+		INSERT INTO chatbot_embedding_settings (chatbot_id, model, task_type, dimensions)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, 0))
+		ON CONFLICT (chatbot_id) DO UPDATE
+		SET model = EXCLUDED.model,
+			task_type = EXCLUDED.task_type,
+			dimensions = EXCLUDED.dimensions,
+			updated_at = now()
+		RETURNING updated_at
+	`, settings.ChatbotID, settings.Model, settings.TaskType, settings.Dimensions).Scan(&stored.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store embedding settings: %w", err)
+	}
+	return &stored, nil
+}
+
+// DeleteChatbotEmbeddingSettings returns the chatbot to the global settings.
+// Returns ErrNotFound if it had no overrides.
+func (c *PostgresClient) DeleteChatbotEmbeddingSettings(ctx context.Context, chatbotID string) error {
+	result, err := c.pool.Exec(ctx, `DELETE FROM chatbot_embedding_settings WHERE chatbot_id = $1`, chatbotID)
+	if err != nil {
+		return fmt.Errorf("failed to delete embedding settings: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+// copyThreshold is the smallest batch worth staging through COPY; below it
+// the temp table costs more than the row by row inserts it replaces, as
+// BenchmarkInsertEmbeddings measures
+const copyThreshold = 50
+
+// defaultCopyBatchSize applies when BATCH_SIZE is unset or invalid
+const defaultCopyBatchSize = 100
+
+// The staging table lives for one transaction and holds one COPY batch at a
+// time. user_id and chatbot_id are the same for every row, so they are passed
+// to the set-based insert instead of being copied.
+const embeddingsStagingSchema = `
+	CREATE TEMP TABLE IF NOT EXISTS embeddings_staging (
+		text             TEXT NOT NULL,
+		vector           vector NOT NULL,
+		created_at       TIMESTAMPTZ NOT NULL,
+		data_source_id   TEXT,
+		citation         TEXT,
+		ingestion_job_id TEXT,
+		metadata         JSONB,
+		topic            TEXT,
+		chunk_index      INTEGER,
+		embedding_model  TEXT
+	) ON COMMIT DROP
+`
+
+var embeddingsStagingColumns = []string{
+	"text", "vector", "created_at", "data_source_id", "citation",
+	"ingestion_job_id", "metadata", "topic", "chunk_index", "embedding_model",
+}
+
+// copyEmbeddings streams chunks into the staging table with COPY in batches of
+// copyBatchSize, moving each batch into embeddings with a single INSERT ...
+// SELECT. A batch that fails is retried row by row so only the bad rows are
+// rejected.
+func (c *PostgresClient) copyEmbeddings(ctx context.Context, tx pgx.Tx, userID, chatbotID string, chunks []EmbeddingData) (*InsertResult, error) {
+	start := time.Now()
+
+	if _, err := tx.Exec(ctx, embeddingsStagingSchema); err != nil {
+		return nil, fmt.Errorf("failed to create embeddings staging table: %w", err)
+	}
+
+	result := &InsertResult{}
+	for i := 0; i < len(chunks); i += c.copyBatchSize {
+		end := i + c.copyBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		batch := chunks[i:end]
+
+		n, err := copyEmbeddingBatch(ctx, tx, userID, chatbotID, c.searchLanguage, batch)
+		if err == nil {
+			result.Inserted += n
+			continue
+		}
+
+		log.Printf("COPY of %d embeddings failed, falling back to row inserts: %v", len(batch), err)
+		rowResult, err := insertEmbeddingRows(ctx, tx, userID, chatbotID, c.searchLanguage, batch, i)
+		if err != nil {
+			return nil, err
+		}
+		result.Inserted += rowResult.Inserted
+		result.Rejected = append(result.Rejected, rowResult.Rejected...)
+	}
+
+	elapsed := time.Since(start)
+	log.Printf("Bulk inserted %d embeddings in %s (%.0f rows/s, batch size %d)",
+		result.Inserted, elapsed.Round(time.Millisecond), float64(result.Inserted)/elapsed.Seconds(), c.copyBatchSize)
+	return result, nil
+}
+
+// copyEmbeddingBatch stages one batch and inserts it under a savepoint, so a
+// failure leaves the transaction usable
+func copyEmbeddingBatch(ctx context.Context, tx pgx.Tx, userID, chatbotID, searchLanguage string, batch []EmbeddingData) (int, error) {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	defer savepoint.Rollback(ctx)
+
+	now := time.Now().UTC()
+	rows := make([][]any, len(batch))
+	for i, chunk := range batch {
+		rows[i] = []any{
+			chunk.Text,
+			pgvector.NewVector(chunk.Vector),
+			now,
+			chunk.DataSourceID,
+			chunk.Citation,
+			chunk.IngestionJobID,
+			encodeChunkMetadata(chunk),
+			chunk.Topic,
+			chunk.ChunkIndex,
+			chunk.Model,
+		}
+	}
+
+	if _, err := savepoint.CopyFrom(ctx, pgx.Identifier{"embeddings_staging"}, embeddingsStagingColumns, pgx.CopyFromRows(rows)); err != nil {
+		return 0, fmt.Errorf("failed to copy embeddings: %w", err)
+	}
+
+	result, err := savepoint.Exec(ctx, `
+		INSERT INTO embeddings (
+			user_id, chatbot_id, text, vector,
+			created_at, updated_at, data_source_id, citation, ingestion_job_id, text_search,
+			metadata, topic, chunk_index, embedding_model, embedding_dimensions
+		)
+		SELECT $1, $2, text, vector,
+			created_at, created_at, data_source_id, citation, ingestion_job_id, to_tsvector($3::regconfig, text),
+			metadata, NULLIF(topic, ''), chunk_index, NULLIF(embedding_model, ''), vector_dims(vector)
+		FROM embeddings_staging
+	`, userID, chatbotID, searchLanguage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert staged embeddings: %w", err)
+	}
+
+	if _, err := savepoint.Exec(ctx, `TRUNCATE embeddings_staging`); err != nil {
+		return 0, fmt.Errorf("failed to clear embeddings staging table: %w", err)
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
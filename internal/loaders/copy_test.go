@@ -0,0 +1,77 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BenchmarkInsertEmbeddings compares staging rows through COPY with inserting
+// them one by one, the two paths insertEmbeddings picks between at
+// copyThreshold. It needs a migrated database in TEST_DATABASE_URL; every
+// iteration is rolled back.
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/loaders -run '^$' -bench InsertEmbeddings
+func BenchmarkInsertEmbeddings(b *testing.B) {
+	client := testClient(b, defaultCopyBatchSize)
+	ctx := context.Background()
+	const dimensions = 768
+
+	paths := []struct {
+		name   string
+		insert func(ctx context.Context, tx pgx.Tx, chunks []EmbeddingData) (*InsertResult, error)
+	}{
+		{name: "rows", insert: func(ctx context.Context, tx pgx.Tx, chunks []EmbeddingData) (*InsertResult, error) {
+			return insertEmbeddingRows(ctx, tx, "bench-user", "bench-chatbot", client.searchLanguage, chunks, 0)
+		}},
+		{name: "copy", insert: func(ctx context.Context, tx pgx.Tx, chunks []EmbeddingData) (*InsertResult, error) {
+			return client.copyEmbeddings(ctx, tx, "bench-user", "bench-chatbot", chunks)
+		}},
+	}
+
+	for _, size := range []int{10, copyThreshold, 200, 1000} {
+		chunks := benchmarkChunks(size, dimensions)
+		for _, path := range paths {
+			b.Run(fmt.Sprintf("%s/%d", path.name, size), func(b *testing.B) {
+				iterations := 0
+				for b.Loop() {
+					iterations++
+					tx, err := client.pool.Begin(ctx)
+					if err != nil {
+						b.Fatalf("Begin() error = %v", err)
+					}
+					result, err := path.insert(ctx, tx, chunks)
+					tx.Rollback(ctx)
+					if err != nil {
+						b.Fatalf("insert error = %v", err)
+					}
+					if result.Inserted != size {
+						b.Fatalf("inserted %d rows, want %d", result.Inserted, size)
+					}
+				}
+				b.ReportMetric(float64(size*iterations)/b.Elapsed().Seconds(), "rows/s")
+			})
+		}
+	}
+}
+
+func benchmarkChunks(n, dimensions int) []EmbeddingData {
+	chunks := make([]EmbeddingData, n)
+	for i := range chunks {
+		vector := make([]float32, dimensions)
+		vector[i%dimensions] = 1
+		index := i
+		chunks[i] = EmbeddingData{
+			Topic:      "benchmark",
+			Text:       strings.Repeat(fmt.Sprintf("chunk %d of the benchmark document. ", i), 20),
+			Vector:     vector,
+			Metadata:   map[string]interface{}{"page": i / 10},
+			ChunkIndex: &index,
+			Model:      "hash/hash-v1",
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,64 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+// GetCachedEmbeddings returns the cached vectors among hashes, keyed by hash.
+// Hashes that are not cached are absent from the result.
+func (c *PostgresClient) GetCachedEmbeddings(ctx context.Context, model, taskType string, dimensions int, hashes [][32]byte) (map[[32]byte][]float32, error) {
+	keys := make([][]byte, len(hashes))
+	for i := range hashes {
+		keys[i] = hashes[i][:]
+	}
+
+	rows, err := c.pool.Query(ctx, `
+		SELECT text_hash, vector FROM embedding_cache
+		WHERE model = $1 AND task_type = $2 AND dimensions = $3 AND text_hash = ANY($4)
+	`, model, taskType, dimensions, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embedding cache: %w", err)
+	}
+	defer rows.Close()
+
+	cached := make(map[[32]byte][]float32)
+	for rows.Next() {
+		var key []byte
+		var vector pgvector.Vector
+		if err := rows.Scan(&key, &vector); err != nil {
+			return nil, fmt.Errorf("failed to scan cached embedding: %w", err)
+		}
+		if len(key) != 32 {
+			continue
+		}
+		cached[[32]byte(key)] = vector.Slice()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read embedding cache: %w", err)
+	}
+	return cached, nil
+}
+
+// PutCachedEmbeddings stores vectors by text hash, keeping any already cached
+func (c *PostgresClient) PutCachedEmbeddings(ctx context.Context, model, taskType string, dimensions int, vectors map[[32]byte][]float32) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for hash, vector := range vectors {
+		batch.Queue(`
+			INSERT INTO embedding_cache (model, task_type, dimensions, text_hash, vector)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING
+		`, model, taskType, dimensions, hash[:], pgvector.NewVector(vector))
+	}
+	if err := c.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to store cached embeddings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// DefaultSearchLanguage is the text search configuration used for full-text
+// indexing unless SEARCH_LANGUAGE selects another
+const DefaultSearchLanguage = "english"
+
+// SetSearchLanguage selects the Postgres text search configuration used to
+// index chunks and parse keyword queries, e.g. "english" or "simple"
+func (c *PostgresClient) SetSearchLanguage(ctx context.Context, language string) error {
+	if _, err := c.pool.Exec(ctx, `SELECT $1::regconfig`, language); err != nil {
+		return fmt.Errorf("unknown text search configuration %q: %w", language, err)
+	}
+	c.searchLanguage = language
+	return nil
+}
+
+// ReplaceEmbeddings inserts the final batch of an ingestion job for a datasource
+// and, in the same transaction, deletes the datasource's rows written by any
+// other job. The result's Removed holds the number of rows deleted; nothing is
+// removed when every chunk was rejected.
+func (c *PostgresClient) ReplaceEmbeddings(ctx context.Context, userID, chatbotID, ingestionJobID, dataSourceID string, chunks []EmbeddingData) (*InsertResult, error) {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result := &InsertResult{}
+	if len(chunks) > 0 {
+		result, err = c.insertEmbeddings(ctx, tx, userID, chatbotID, chunks)
+		if err != nil {
+			return nil, err
+		}
+		if result.Inserted == 0 {
+			// Nothing of the new generation made it; keep serving the old one
+			return result, nil
+		}
+	}
+
+	deleted, err := tx.Exec(ctx, `
+		DELETE FROM embeddings
+		WHERE data_source_id = $1 AND ingestion_job_id IS DISTINCT FROM $2
+	`, dataSourceID, ingestionJobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete previous embeddings: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	result.Removed = deleted.RowsAffected()
+	log.Printf("Replaced embeddings for data_source_id=%s: removed %d previous rows", dataSourceID, result.Removed)
+	return result, nil
+}
+
+// DeleteIngestionJobEmbeddings removes the rows a job wrote for a datasource,
+// used to roll back a replacing job that did not finish
+func (c *PostgresClient) DeleteIngestionJobEmbeddings(ctx context.Context, ingestionJobID, dataSourceID string) (int64, error) {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM embeddings WHERE ingestion_job_id = $1 AND data_source_id = $2
+	`, ingestionJobID, dataSourceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete ingestion job embeddings: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeleteDatasourceEmbeddings removes every embedding of a datasource
+func (c *PostgresClient) DeleteDatasourceEmbeddings(ctx context.Context, dataSourceID string) (int64, error) {
+	result, err := c.pool.Exec(ctx, `DELETE FROM embeddings WHERE data_source_id = $1`, dataSourceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete datasource embeddings: %w", err)
+	}
+	log.Printf("Deleted %d embeddings for data_source_id=%s", result.RowsAffected(), dataSourceID)
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,84 @@
+package loaders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+// AppendIngestionJobEvent records a progress event for a job
+func (c *PostgresClient) AppendIngestionJobEvent(ctx context.Context, jobID, eventType, datasourceID string, data map[string]interface{}) error {
+	var payload []byte
+	if data != nil {
+		var err error
+		payload, err = json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("failed to encode event data: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO ingestion_job_events (job_id, type, datasource_id, data)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+	`
+	if _, err := c.pool.Exec(ctx, query, jobID, eventType, datasourceID, payload); err != nil {
+		return fmt.Errorf("failed to append ingestion job event: %w", err)
+	}
+	return nil
+}
+
+// ListIngestionJobEvents returns up to limit events of a job with an ID greater
+// than afterID, oldest first. IDs are assigned before commit, so an event can
+// become visible after one with a higher ID; events created within lookback
+// are returned as well unless their ID is in sent.
+func (c *PostgresClient) ListIngestionJobEvents(ctx context.Context, jobID string, afterID int64, lookback time.Duration, sent []int64, limit int) ([]types.JobEvent, error) {
+	query := `
+		SELECT id, job_id, type, COALESCE(datasource_id, ''), data, created_at
+		FROM ingestion_job_events
+		WHERE job_id = $1
+			AND (id > $2 OR (created_at > now() - make_interval(secs => $3) AND NOT id = ANY($4)))
+		ORDER BY id
+		LIMIT $5
+	`
+	if sent == nil {
+		sent = []int64{}
+	}
+	rows, err := c.pool.Query(ctx, query, jobID, afterID, lookback.Seconds(), sent, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingestion job events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []types.JobEvent
+	for rows.Next() {
+		var event types.JobEvent
+		var data []byte
+		if err := rows.Scan(&event.ID, &event.JobID, &event.Type, &event.DatasourceID, &data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ingestion job event: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &event.Data); err != nil {
+				return nil, fmt.Errorf("failed to decode event data: %w", err)
+			}
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ingestion job events: %w", err)
+	}
+	return events, nil
+}
+
+// PruneIngestionJobEvents deletes events older than maxAge
+func (c *PostgresClient) PruneIngestionJobEvents(ctx context.Context, maxAge time.Duration) (int64, error) {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM ingestion_job_events WHERE created_at < now() - make_interval(secs => $1)
+	`, maxAge.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune ingestion job events: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
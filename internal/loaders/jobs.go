@@ -0,0 +1,512 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound is returned when a requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrJobFinished is returned when cancelling a job that already reached a terminal state
+	ErrJobFinished = errors.New("job already finished")
+	// ErrJobCancelled is returned when recording progress for a cancelled job
+	ErrJobCancelled = errors.New("job cancelled")
+	// ErrSourcesRecorded is returned when a redelivered job's sources were
+	// already recorded and its embedding work enqueued
+	ErrSourcesRecorded = errors.New("job sources already recorded")
+	// ErrIdempotencyKeyExists is returned when another job already holds the chatbot's idempotency key
+	ErrIdempotencyKeyExists = errors.New("idempotency key already used")
+)
+
+// CreateIngestionJob records a newly accepted job in the pending state. It
+// returns ErrIdempotencyKeyExists if the record's idempotency key is taken.
+func (c *PostgresClient) CreateIngestionJob(ctx context.Context, record types.IngestionRecord) error {
+	query := `
+		INSERT INTO ingestion_jobs (
+			id, user_id, chatbot_id, status, total_sources, datasource_ids, callback_url,
+			idempotency_key, request_hash, response, metadata
+		) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''), $10, $11)
+	`
+	_, err := c.pool.Exec(ctx, query,
+		record.ID,
+		record.UserID,
+		record.ChatbotID,
+		string(record.Status),
+		record.TotalSources,
+		record.DatasourceIDs,
+		record.CallbackURL,
+		record.IdempotencyKey,
+		record.RequestHash,
+		record.Response,
+		record.Metadata,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_ingestion_jobs_idempotency_key" {
+		return ErrIdempotencyKeyExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create ingestion job: %w", err)
+	}
+	return nil
+}
+
+// FindIdempotentJob returns the job created for the chatbot's idempotency key
+// within window, with only the idempotency fields set. Keys older than window
+// are released first so they can be reused. Returns ErrNotFound if there is none.
+func (c *PostgresClient) FindIdempotentJob(ctx context.Context, chatbotID, key string, window time.Duration) (*types.IngestionRecord, error) {
+	if _, err := c.pool.Exec(ctx, `
+		UPDATE ingestion_jobs
+		SET idempotency_key = NULL, updated_at = now()
+		WHERE chatbot_id = $1 AND idempotency_key = $2
+			AND created_at < now() - make_interval(secs => $3)
+	`, chatbotID, key, window.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to release expired idempotency key: %w", err)
+	}
+
+	var record types.IngestionRecord
+	err := c.pool.QueryRow(ctx, `
+		SELECT id, chatbot_id, idempotency_key, COALESCE(request_hash, ''), response
+		FROM ingestion_jobs
+		WHERE chatbot_id = $1 AND idempotency_key = $2
+	`, chatbotID, key).Scan(
+		&record.ID,
+		&record.ChatbotID,
+		&record.IdempotencyKey,
+		&record.RequestHash,
+		&record.Response,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find idempotent job: %w", err)
+	}
+	return &record, nil
+}
+
+// ReleaseIdempotencyKey frees the key held by a job that was never enqueued so
+// the client's retry creates a new one
+func (c *PostgresClient) ReleaseIdempotencyKey(ctx context.Context, jobID string) error {
+	query := `UPDATE ingestion_jobs SET idempotency_key = NULL, updated_at = now() WHERE id = $1`
+	if _, err := c.pool.Exec(ctx, query, jobID); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// UpdateIngestionJobStatus sets the job status without touching its counters
+func (c *PostgresClient) UpdateIngestionJobStatus(ctx context.Context, jobID string, status types.ProcessStatus, errorMessage string) error {
+	query := `
+		UPDATE ingestion_jobs
+		SET status = $2,
+			error_message = NULLIF($3, ''),
+			updated_at = now(),
+			completed_at = CASE WHEN $4 THEN now() ELSE completed_at END
+		WHERE id = $1 AND status <> $5
+	`
+	_, err := c.pool.Exec(ctx, query, jobID, string(status), errorMessage, isTerminalStatus(status), string(types.StatusCancelled))
+	if err != nil {
+		return fmt.Errorf("failed to update ingestion job status: %w", err)
+	}
+	return nil
+}
+
+// RecordIngestionJobSources stores the chunking outcome of every source, enqueues
+// work for the embedding workers and moves the job into the embedding phase, all
+// in one transaction. pendingSources is the number of datasources handed to the
+// embedding workers; when zero the job is finalized here. A job that is no
+// longer pending or processing was recorded by an earlier delivery and gets
+// ErrSourcesRecorded, so redelivered items never enqueue the same work twice.
+func (c *PostgresClient) RecordIngestionJobSources(ctx context.Context, jobID string, results []types.SourceResult, totalChunks, pendingSources int, work []NewWork) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var current string
+	err = tx.QueryRow(ctx, `SELECT status FROM ingestion_jobs WHERE id = $1 FOR UPDATE`, jobID).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ingestion job: %w", err)
+	}
+	cancelled := current == string(types.StatusCancelled)
+	if !cancelled && current != string(types.StatusPending) && current != string(types.StatusProcessing) {
+		return ErrSourcesRecorded
+	}
+
+	sourceQuery := `
+		INSERT INTO ingestion_job_sources (
+			job_id, datasource_id, source_type, source, status,
+			message, error, chunk_count, processed_at
+		) VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9)
+		ON CONFLICT (job_id, datasource_id) DO UPDATE SET
+			source_type = EXCLUDED.source_type,
+			source = EXCLUDED.source,
+			status = EXCLUDED.status,
+			message = EXCLUDED.message,
+			error = EXCLUDED.error,
+			chunk_count = EXCLUDED.chunk_count,
+			processed_at = EXCLUDED.processed_at,
+			updated_at = now()
+	`
+
+	processed := 0
+	failed := 0
+	for _, result := range results {
+		if cancelled && result.Status != types.SourceStatusFailed {
+			result.Status = types.SourceStatusCancelled
+		}
+		switch result.Status {
+		case types.SourceStatusFailed:
+			failed++
+		case types.SourceStatusCompleted:
+			processed++
+		}
+
+		if _, err := tx.Exec(ctx, sourceQuery,
+			jobID,
+			result.DatasourceID,
+			string(result.SourceType),
+			result.Source,
+			result.Status,
+			result.Message,
+			result.Error,
+			result.ChunkCount,
+			result.ProcessedAt,
+		); err != nil {
+			return fmt.Errorf("failed to record source %s: %w", result.DatasourceID, err)
+		}
+	}
+
+	if cancelled {
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return ErrJobCancelled
+	}
+
+	status := types.StatusEmbedding
+	if pendingSources == 0 {
+		status = finalJobStatus(processed, failed)
+	}
+
+	jobQuery := `
+		UPDATE ingestion_jobs
+		SET status = $2,
+			total_chunks = $3,
+			processed_sources = $4,
+			failed_sources = $5,
+			pending_sources = $6,
+			updated_at = now(),
+			completed_at = CASE WHEN $7 THEN now() ELSE completed_at END
+		WHERE id = $1
+	`
+	if _, err := tx.Exec(ctx, jobQuery, jobID, string(status), totalChunks, processed, failed, pendingSources, isTerminalStatus(status)); err != nil {
+		return fmt.Errorf("failed to update ingestion job: %w", err)
+	}
+
+	for _, item := range work {
+		if err := enqueueWork(ctx, tx, item.Kind, item.JobID, jobID, item.Payload, item.MaxAttempts); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// AddIngestionJobProgress counts chunks that were embedded and persisted for a
+// datasource that still has chunks outstanding
+func (c *PostgresClient) AddIngestionJobProgress(ctx context.Context, jobID, datasourceID string, embeddedChunks int) error {
+	if embeddedChunks == 0 {
+		return nil
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE ingestion_job_sources
+		SET embedded_chunks = embedded_chunks + $3, updated_at = now()
+		WHERE job_id = $1 AND datasource_id = $2
+	`, jobID, datasourceID, embeddedChunks); err != nil {
+		return fmt.Errorf("failed to update source progress: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE ingestion_jobs
+		SET embedded_chunks = embedded_chunks + $2, updated_at = now()
+		WHERE id = $1
+	`, jobID, embeddedChunks); err != nil {
+		return fmt.Errorf("failed to update job progress: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SourceOutcome describes the effect of FinishIngestionJobSource
+type SourceOutcome struct {
+	JobStatus     types.ProcessStatus
+	SourceUpdated bool // false when the source had already reached a final state
+	JobFinished   bool // true when this update moved the job to a terminal state
+}
+
+// FinishIngestionJobSource marks a datasource that was waiting on embeddings as
+// completed (errorMessage empty) or failed, and finalizes the job once no
+// datasources remain pending.
+func (c *PostgresClient) FinishIngestionJobSource(ctx context.Context, jobID, datasourceID string, embeddedChunks int, errorMessage string) (*SourceOutcome, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sourceStatus := types.SourceStatusCompleted
+	processedDelta, failedDelta := 1, 0
+	if errorMessage != "" {
+		sourceStatus = types.SourceStatusFailed
+		processedDelta, failedDelta = 0, 1
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE ingestion_job_sources
+		SET status = $3,
+			error = NULLIF($4, ''),
+			embedded_chunks = embedded_chunks + $5,
+			updated_at = now()
+		WHERE job_id = $1 AND datasource_id = $2 AND status = $6
+	`, jobID, datasourceID, sourceStatus, errorMessage, embeddedChunks, types.SourceStatusEmbedding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update source status: %w", err)
+	}
+
+	var status string
+	var pending, processed, failed int
+
+	if result.RowsAffected() == 0 {
+		// Already finished by an earlier attempt; report the current state
+		err := tx.QueryRow(ctx, `SELECT status FROM ingestion_jobs WHERE id = $1`, jobID).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ingestion job: %w", err)
+		}
+		return &SourceOutcome{JobStatus: types.ProcessStatus(status)}, nil
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE ingestion_jobs
+		SET embedded_chunks = embedded_chunks + $2,
+			processed_sources = processed_sources + $3,
+			failed_sources = failed_sources + $4,
+			pending_sources = GREATEST(pending_sources - 1, 0),
+			updated_at = now()
+		WHERE id = $1
+		RETURNING status, pending_sources, processed_sources, failed_sources
+	`, jobID, embeddedChunks, processedDelta, failedDelta).Scan(&status, &pending, &processed, &failed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update ingestion job: %w", err)
+	}
+
+	outcome := &SourceOutcome{SourceUpdated: true}
+	if pending == 0 && !isTerminalStatus(types.ProcessStatus(status)) {
+		final := finalJobStatus(processed, failed)
+		if _, err := tx.Exec(ctx, `
+			UPDATE ingestion_jobs
+			SET status = $2, completed_at = now(), updated_at = now()
+			WHERE id = $1
+		`, jobID, string(final)); err != nil {
+			return nil, fmt.Errorf("failed to finalize ingestion job: %w", err)
+		}
+		status = string(final)
+		outcome.JobFinished = true
+	}
+	outcome.JobStatus = types.ProcessStatus(status)
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return outcome, nil
+}
+
+// GetIngestionJob loads a job together with its per-source results
+func (c *PostgresClient) GetIngestionJob(ctx context.Context, jobID string) (*types.IngestionRecord, error) {
+	query := `
+		SELECT id, user_id, chatbot_id, status, total_sources, processed_sources,
+			failed_sources, total_chunks, embedded_chunks, pending_sources,
+			COALESCE(datasource_ids, '{}'), COALESCE(callback_url, ''),
+			COALESCE(metadata, '{}'::jsonb), COALESCE(error_message, ''),
+			created_at, updated_at, completed_at
+		FROM ingestion_jobs
+		WHERE id = $1
+	`
+
+	var record types.IngestionRecord
+	var status string
+	err := c.pool.QueryRow(ctx, query, jobID).Scan(
+		&record.ID,
+		&record.UserID,
+		&record.ChatbotID,
+		&status,
+		&record.TotalSources,
+		&record.ProcessedSources,
+		&record.FailedSources,
+		&record.TotalChunks,
+		&record.EmbeddedChunks,
+		&record.PendingSources,
+		&record.DatasourceIDs,
+		&record.CallbackURL,
+		&record.Metadata,
+		&record.ErrorMessage,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+		&record.CompletedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ingestion job: %w", err)
+	}
+	record.Status = types.ProcessStatus(status)
+
+	rows, err := c.pool.Query(ctx, `
+		SELECT datasource_id, source_type, source, status, COALESCE(message, ''),
+			COALESCE(error, ''), chunk_count, embedded_chunks, processed_at
+		FROM ingestion_job_sources
+		WHERE job_id = $1
+		ORDER BY processed_at, datasource_id
+	`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ingestion job sources: %w", err)
+	}
+	defer rows.Close()
+
+	record.Results = []types.SourceResult{}
+	for rows.Next() {
+		var result types.SourceResult
+		var sourceType string
+		var processedAt *time.Time
+		if err := rows.Scan(
+			&result.DatasourceID,
+			&sourceType,
+			&result.Source,
+			&result.Status,
+			&result.Message,
+			&result.Error,
+			&result.ChunkCount,
+			&result.EmbeddedChunks,
+			&processedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan ingestion job source: %w", err)
+		}
+		result.SourceType = types.SourceType(sourceType)
+		if processedAt != nil {
+			result.ProcessedAt = *processedAt
+		}
+		record.Results = append(record.Results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ingestion job sources: %w", err)
+	}
+
+	return &record, nil
+}
+
+// CancelIngestionJob moves a job that has not finished yet to the cancelled
+// state and returns the datasources that had not reached a final outcome
+func (c *PostgresClient) CancelIngestionJob(ctx context.Context, jobID string) ([]string, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM ingestion_jobs WHERE id = $1 FOR UPDATE`, jobID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ingestion job: %w", err)
+	}
+	if status == string(types.StatusCancelled) || isTerminalStatus(types.ProcessStatus(status)) {
+		return nil, ErrJobFinished
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE ingestion_jobs
+		SET status = $2, pending_sources = 0, completed_at = now(), updated_at = now()
+		WHERE id = $1
+	`, jobID, string(types.StatusCancelled)); err != nil {
+		return nil, fmt.Errorf("failed to cancel ingestion job: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE ingestion_job_sources
+		SET status = $2, updated_at = now()
+		WHERE job_id = $1 AND status = $3
+	`, jobID, types.SourceStatusCancelled, types.SourceStatusEmbedding); err != nil {
+		return nil, fmt.Errorf("failed to cancel ingestion job sources: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT unnest(datasource_ids) FROM ingestion_jobs WHERE id = $1
+		EXCEPT
+		SELECT datasource_id FROM ingestion_job_sources
+		WHERE job_id = $1 AND status IN ($2, $3)
+	`, jobID, types.SourceStatusCompleted, types.SourceStatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cancelled datasources: %w", err)
+	}
+	datasourceIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cancelled datasources: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return datasourceIDs, nil
+}
+
+func finalJobStatus(processed, failed int) types.ProcessStatus {
+	if failed == 0 {
+		return types.StatusCompleted
+	}
+	if processed == 0 {
+		return types.StatusFailed
+	}
+	return types.StatusPartial
+}
+
+func isTerminalStatus(status types.ProcessStatus) bool {
+	switch status {
+	case types.StatusCompleted, types.StatusFailed, types.StatusPartial:
+		return true
+	default:
+		return false
+	}
+}
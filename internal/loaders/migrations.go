@@ -0,0 +1,407 @@
+package loaders
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrIncompatibleSchema is returned by CheckSchema when the database does not
+// match the schema this binary was built for
+var ErrIncompatibleSchema = errors.New("incompatible database schema")
+
+// migrationLockID serializes migrations run by concurrent processes
+const migrationLockID = 7_210_443_001
+
+// noTransactionMarker starts migration files whose statements cannot run in a
+// transaction, such as CREATE INDEX CONCURRENTLY. Their statements run one at
+// a time, split at semicolons that end a line, so they cannot contain DO
+// blocks, and must be safe to repeat: a failure leaves earlier ones applied.
+const noTransactionMarker = "-- +migrate notransaction"
+
+const schemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+`
+
+// Migration is one versioned schema change, read from migrations/NNNN_name.{up,down}.sql
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// loadMigrations parses the embedded migration files, ordered by version
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s is not named NNNN_name", fileName)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s has an invalid version", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", fileName, err)
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LatestSchemaVersion returns the version of the newest embedded migration
+func LatestSchemaVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Migrator applies the embedded migrations over a dedicated connection, so it
+// works before the pgvector extension that the pool depends on exists
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+}
+
+func NewMigrator(ctx context.Context, dsn string) (*Migrator, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, schemaMigrationsTable); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+func (m *Migrator) Close(ctx context.Context) error {
+	return m.conn.Close(ctx)
+}
+
+// Up applies every pending migration in order, each in its own transaction
+// unless marked otherwise, and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		log.Printf("Applying migration %04d_%s", migration.Version, migration.Name)
+		err := m.run(ctx, migration.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts up to steps of the most recently applied migrations, newest
+// first, and returns the ones reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		log.Printf("Reverting migration %04d_%s", migration.Version, migration.Name)
+		err := m.run(ctx, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// run executes a migration script followed by the statement recording it, in
+// one transaction unless the script starts with noTransactionMarker
+func (m *Migrator) run(ctx context.Context, script, record string, args ...any) error {
+	if !strings.HasPrefix(script, noTransactionMarker) {
+		return pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, script); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, record, args...)
+			return err
+		})
+	}
+
+	for _, statement := range strings.Split(script, ";\n") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		if _, err := m.conn.Exec(ctx, statement); err != nil {
+			return err
+		}
+	}
+	_, err := m.conn.Exec(ctx, record, args...)
+	return err
+}
+
+// Status lists every embedded migration with the time it was applied, if it was
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := m.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// lock takes a session advisory lock so only one process migrates at a time
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, int64(migrationLockID)); err != nil {
+		return nil, fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	return func() {
+		if _, err := m.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, int64(migrationLockID)); err != nil {
+			log.Printf("Failed to release migration lock: %v", err)
+		}
+	}, nil
+}
+
+// requiredColumns lists the columns of the shared tables the ingestor reads or writes
+var requiredColumns = map[string][]string{
+	"data_source": {"id", "status", "updated_at"},
+	"embeddings": {
+		"id", "user_id", "chatbot_id", "topic", "text", "vector", "created_at", "updated_at",
+		"data_source_id", "citation", "ingestion_job_id", "text_search", "metadata", "chunk_index",
+		"embedding_model", "embedding_dimensions",
+	},
+}
+
+// CheckSchema verifies that every embedded migration has been applied, that
+// the database is not ahead of this binary, and that the tables shared with
+// the main application have the columns the ingestor uses. Errors wrap
+// ErrIncompatibleSchema.
+func (c *PostgresClient) CheckSchema(ctx context.Context) error {
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = c.pool.QueryRow(ctx, `
+		SELECT COALESCE(MAX(version), 0) FROM schema_migrations
+	`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("%w: cannot read schema_migrations, run `migrate up`: %v", ErrIncompatibleSchema, err)
+	}
+	if current < latest {
+		return fmt.Errorf("%w: database is at version %d, this binary needs %d, run `migrate up`", ErrIncompatibleSchema, current, latest)
+	}
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, newer than the %d this binary supports", ErrIncompatibleSchema, current, latest)
+	}
+
+	for table, columns := range requiredColumns {
+		rows, err := c.pool.Query(ctx, `
+			SELECT column_name FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1
+		`, table)
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		existing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+
+		present := make(map[string]bool, len(existing))
+		for _, column := range existing {
+			present[column] = true
+		}
+		var missing []string
+		for _, column := range columns {
+			if !present[column] {
+				missing = append(missing, column)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%w: table %s is missing columns %s", ErrIncompatibleSchema, table, strings.Join(missing, ", "))
+		}
+	}
+
+	return nil
+}
+
+// MaxIndexedDimensions is the largest vector pgvector can index
+const MaxIndexedDimensions = 2000
+
+// EnsureVectorIndex creates the partial cosine distance index over embeddings
+// of the given size unless it exists. It is built concurrently so ingestion
+// and search continue meanwhile; HNSW is used when pgvector supports it.
+func (c *PostgresClient) EnsureVectorIndex(ctx context.Context, dimensions int) error {
+	if dimensions <= 0 || dimensions > MaxIndexedDimensions {
+		return fmt.Errorf("cannot index vectors of %d dimensions, the limit is %d", dimensions, MaxIndexedDimensions)
+	}
+
+	name := fmt.Sprintf("idx_embeddings_vector_%d", dimensions)
+	var valid *bool
+	err := c.pool.QueryRow(ctx, `
+		SELECT i.indisvalid FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = $1 AND n.nspname = current_schema()
+	`, name).Scan(&valid)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to look up index %s: %w", name, err)
+	}
+	if valid != nil && *valid {
+		return nil
+	}
+	if valid != nil {
+		// Left behind by an interrupted concurrent build
+		if _, err := c.pool.Exec(ctx, fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s`, name)); err != nil {
+			return fmt.Errorf("failed to drop invalid index %s: %w", name, err)
+		}
+	}
+
+	var hasHNSW bool
+	if err := c.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_am WHERE amname = 'hnsw')`).Scan(&hasHNSW); err != nil {
+		return fmt.Errorf("failed to check for HNSW support: %w", err)
+	}
+	method := "ivfflat ((vector::vector(%[2]d)) vector_cosine_ops) WITH (lists = 100)"
+	if hasHNSW {
+		method = "hnsw ((vector::vector(%[2]d)) vector_cosine_ops)"
+	}
+
+	log.Printf("Creating vector index %s", name)
+	query := fmt.Sprintf(`CREATE INDEX CONCURRENTLY IF NOT EXISTS %[1]s ON embeddings USING `+method+` WHERE embedding_dimensions = %[2]d`, name, dimensions)
+	if _, err := c.pool.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", name, err)
+	}
+	return nil
+}
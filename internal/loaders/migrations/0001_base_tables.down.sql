@@ -0,0 +1,2 @@
+-- data_source and embeddings are shared with the main application and may
+-- hold its data, so reverting this migration leaves them in place
@@ -0,0 +1,28 @@
+-- data_source and embeddings are shared with the main application, which may
+-- already have created them
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS data_source (
+	id             TEXT PRIMARY KEY,
+	chatbot_id     TEXT NOT NULL,
+	type           TEXT NOT NULL,
+	name           TEXT NOT NULL,
+	source_details JSONB,
+	citation       TEXT,
+	status         TEXT,
+	created_at     TIMESTAMPTZ DEFAULT now(),
+	updated_at     TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS embeddings (
+	id             BIGSERIAL PRIMARY KEY,
+	user_id        TEXT NOT NULL,
+	chatbot_id     TEXT NOT NULL,
+	topic          TEXT,
+	text           TEXT NOT NULL,
+	vector         vector(768) NOT NULL,
+	created_at     TIMESTAMPTZ DEFAULT now(),
+	updated_at     TIMESTAMPTZ DEFAULT now(),
+	data_source_id TEXT,
+	citation       TEXT
+);
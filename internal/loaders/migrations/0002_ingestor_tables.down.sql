@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS ingestion_job_events;
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS work_queue;
+DROP TABLE IF EXISTS ingestion_job_sources;
+DROP TABLE IF EXISTS ingestion_jobs;
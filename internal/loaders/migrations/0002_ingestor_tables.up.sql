@@ -0,0 +1,105 @@
+CREATE TABLE IF NOT EXISTS ingestion_jobs (
+	id                TEXT PRIMARY KEY,
+	user_id           TEXT NOT NULL,
+	chatbot_id        TEXT NOT NULL,
+	status            TEXT NOT NULL,
+	total_sources     INTEGER NOT NULL DEFAULT 0,
+	processed_sources INTEGER NOT NULL DEFAULT 0,
+	failed_sources    INTEGER NOT NULL DEFAULT 0,
+	total_chunks      INTEGER NOT NULL DEFAULT 0,
+	embedded_chunks   INTEGER NOT NULL DEFAULT 0,
+	pending_sources   INTEGER NOT NULL DEFAULT 0,
+	metadata          JSONB,
+	error_message     TEXT,
+	created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	completed_at      TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS ingestion_job_sources (
+	job_id          TEXT NOT NULL REFERENCES ingestion_jobs(id) ON DELETE CASCADE,
+	datasource_id   TEXT NOT NULL,
+	source_type     TEXT NOT NULL,
+	source          TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	message         TEXT,
+	error           TEXT,
+	chunk_count     INTEGER NOT NULL DEFAULT 0,
+	embedded_chunks INTEGER NOT NULL DEFAULT 0,
+	processed_at    TIMESTAMPTZ,
+	updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (job_id, datasource_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingestion_jobs_chatbot_id ON ingestion_jobs (chatbot_id);
+
+-- Databases set up before migrations existed may have an older ingestion_jobs
+ALTER TABLE ingestion_jobs ADD COLUMN IF NOT EXISTS datasource_ids TEXT[];
+ALTER TABLE ingestion_jobs ADD COLUMN IF NOT EXISTS callback_url TEXT;
+ALTER TABLE ingestion_jobs ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
+ALTER TABLE ingestion_jobs ADD COLUMN IF NOT EXISTS request_hash TEXT;
+ALTER TABLE ingestion_jobs ADD COLUMN IF NOT EXISTS response JSONB;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingestion_jobs_idempotency_key
+	ON ingestion_jobs (chatbot_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS work_queue (
+	id               BIGSERIAL PRIMARY KEY,
+	kind             TEXT NOT NULL,
+	job_id           TEXT NOT NULL,
+	ingestion_job_id TEXT,
+	payload          JSONB NOT NULL,
+	status           TEXT NOT NULL DEFAULT 'queued',
+	attempts         INTEGER NOT NULL DEFAULT 0,
+	max_attempts     INTEGER NOT NULL DEFAULT 5,
+	available_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+	lease_owner      TEXT,
+	lease_expires_at TIMESTAMPTZ,
+	last_error       TEXT,
+	created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_queue_claim ON work_queue (status, available_at, id);
+CREATE INDEX IF NOT EXISTS idx_work_queue_ingestion_job_id ON work_queue (ingestion_job_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id               TEXT PRIMARY KEY,
+	job_id           TEXT NOT NULL,
+	event_type       TEXT NOT NULL,
+	url              TEXT NOT NULL,
+	payload          JSONB NOT NULL,
+	status           TEXT NOT NULL DEFAULT 'pending',
+	attempts         INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_status_code INTEGER,
+	last_error       TEXT,
+	created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at     TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+	id           BIGSERIAL PRIMARY KEY,
+	delivery_id  TEXT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+	attempt      INTEGER NOT NULL,
+	status_code  INTEGER,
+	error        TEXT,
+	duration_ms  BIGINT NOT NULL DEFAULT 0,
+	attempted_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_job_id ON webhook_deliveries (job_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts (delivery_id);
+
+CREATE TABLE IF NOT EXISTS ingestion_job_events (
+	id            BIGSERIAL PRIMARY KEY,
+	job_id        TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	datasource_id TEXT,
+	data          JSONB,
+	created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingestion_job_events_job_id ON ingestion_job_events (job_id, id);
@@ -0,0 +1,8 @@
+-- topic is left in place, it belongs to the base embeddings table
+DROP INDEX IF EXISTS idx_embeddings_metadata;
+DROP INDEX IF EXISTS idx_embeddings_text_search;
+
+ALTER TABLE embeddings DROP COLUMN IF EXISTS chunk_index;
+ALTER TABLE embeddings DROP COLUMN IF EXISTS metadata;
+ALTER TABLE embeddings DROP COLUMN IF EXISTS text_search;
+ALTER TABLE embeddings DROP COLUMN IF EXISTS ingestion_job_id;
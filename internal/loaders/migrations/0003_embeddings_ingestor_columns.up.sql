@@ -0,0 +1,10 @@
+-- Columns the ingestor adds to the shared embeddings table: the job that wrote
+-- each row, full-text search, and the chunk metadata built by the processors
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS ingestion_job_id TEXT;
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS text_search TSVECTOR;
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS metadata JSONB;
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS topic TEXT;
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS chunk_index INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_embeddings_text_search ON embeddings USING GIN (text_search);
+CREATE INDEX IF NOT EXISTS idx_embeddings_metadata ON embeddings USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,2 @@
+-- The up step only creates indexes that do not exist yet, so they may belong
+-- to the main application; reverting this migration leaves them in place
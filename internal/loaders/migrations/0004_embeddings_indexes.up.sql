@@ -0,0 +1,16 @@
+CREATE INDEX IF NOT EXISTS idx_embeddings_chatbot_id ON embeddings (chatbot_id);
+CREATE INDEX IF NOT EXISTS idx_embeddings_data_source_id ON embeddings (data_source_id);
+
+-- Approximate nearest neighbour index for cosine distance. HNSW needs
+-- pgvector 0.5.0 or later; older installs fall back to IVFFlat.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_am WHERE amname = 'hnsw') THEN
+		CREATE INDEX IF NOT EXISTS idx_embeddings_vector ON embeddings
+			USING hnsw (vector vector_cosine_ops);
+	ELSE
+		CREATE INDEX IF NOT EXISTS idx_embeddings_vector ON embeddings
+			USING ivfflat (vector vector_cosine_ops) WITH (lists = 100);
+	END IF;
+END
+$$;
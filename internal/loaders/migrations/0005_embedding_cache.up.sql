@@ -0,0 +1,11 @@
+-- Vectors of previously embedded text, shared by every chatbot. The key covers
+-- everything that changes the vector for the same text.
+CREATE TABLE IF NOT EXISTS embedding_cache (
+	model      TEXT NOT NULL,
+	task_type  TEXT NOT NULL,
+	dimensions INTEGER NOT NULL,
+	text_hash  BYTEA NOT NULL,
+	vector     vector NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (model, task_type, dimensions, text_hash)
+);
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS chatbot_embedding_settings;
+
+-- The per-size vector indexes are only ever created by the ingestor
+DO $$
+DECLARE
+	idx RECORD;
+BEGIN
+	FOR idx IN
+		SELECT indexname FROM pg_indexes
+		WHERE tablename = 'embeddings' AND indexname ~ '^idx_embeddings_vector_[0-9]+$'
+	LOOP
+		EXECUTE format('DROP INDEX IF EXISTS %I', idx.indexname);
+	END LOOP;
+END
+$$;
+
+ALTER TABLE embeddings DROP COLUMN IF EXISTS embedding_dimensions;
+ALTER TABLE embeddings DROP COLUMN IF EXISTS embedding_model;
@@ -0,0 +1,23 @@
+-- Tag every embedding with the model and dimensionality that produced it, so
+-- generations from different models can share the table. Each size in use
+-- gets its own partial index, built concurrently by the ingestor at startup
+-- and when a chatbot switches dimensions. The vector column belongs to the
+-- main application and is left as it is: until the application drops its fixed
+-- size, only vectors of that size can be stored.
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS embedding_model TEXT;
+ALTER TABLE embeddings ADD COLUMN IF NOT EXISTS embedding_dimensions INTEGER;
+
+-- Rows written before tagging all came from the original Gemini model
+UPDATE embeddings
+SET embedding_model = COALESCE(embedding_model, 'gemini/text-embedding-004'),
+	embedding_dimensions = COALESCE(embedding_dimensions, vector_dims(vector))
+WHERE embedding_model IS NULL OR embedding_dimensions IS NULL;
+
+CREATE TABLE IF NOT EXISTS chatbot_embedding_settings (
+	chatbot_id TEXT PRIMARY KEY,
+	model      TEXT,
+	task_type  TEXT,
+	dimensions INTEGER,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reembed_run_chatbots;
+DROP TABLE IF EXISTS reembed_vectors;
+DROP TABLE IF EXISTS reembed_runs;
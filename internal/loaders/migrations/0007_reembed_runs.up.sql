@@ -0,0 +1,43 @@
+-- A re-embedding run recomputes the embeddings of one chatbot, or of every
+-- chatbot, with new settings. Vectors are written to reembed_vectors and only
+-- swapped into embeddings at cutover, which keeps the replaced vectors there
+-- so the run can be rolled back.
+CREATE TABLE IF NOT EXISTS reembed_runs (
+	id                TEXT PRIMARY KEY,
+	chatbot_id        TEXT,
+	model             TEXT,
+	task_type         TEXT,
+	dimensions        INTEGER,
+	model_id          TEXT NOT NULL,
+	target_dimensions INTEGER NOT NULL,
+	status            TEXT NOT NULL,
+	total_rows        INTEGER NOT NULL DEFAULT 0,
+	embedded_rows     INTEGER NOT NULL DEFAULT 0,
+	last_embedding_id BIGINT NOT NULL DEFAULT 0,
+	error_message     TEXT,
+	created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	cutover_at        TIMESTAMPTZ,
+	rolled_back_at    TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS reembed_vectors (
+	run_id               TEXT NOT NULL REFERENCES reembed_runs(id) ON DELETE CASCADE,
+	embedding_id         BIGINT NOT NULL,
+	vector               vector NOT NULL,
+	previous_vector      vector,
+	previous_model       TEXT,
+	previous_dimensions  INTEGER,
+	PRIMARY KEY (run_id, embedding_id)
+);
+
+-- The settings each chatbot had before cutover, restored on rollback
+CREATE TABLE IF NOT EXISTS reembed_run_chatbots (
+	run_id              TEXT NOT NULL REFERENCES reembed_runs(id) ON DELETE CASCADE,
+	chatbot_id          TEXT NOT NULL,
+	had_override        BOOLEAN NOT NULL,
+	previous_model      TEXT,
+	previous_task_type  TEXT,
+	previous_dimensions INTEGER,
+	PRIMARY KEY (run_id, chatbot_id)
+);
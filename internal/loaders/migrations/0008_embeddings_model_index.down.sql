@@ -0,0 +1,2 @@
+-- +migrate notransaction
+DROP INDEX CONCURRENTLY IF EXISTS idx_embeddings_chatbot_model;
@@ -0,0 +1,4 @@
+-- +migrate notransaction
+-- Built concurrently so ingestion and search continue on large tables
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_embeddings_chatbot_model
+	ON embeddings (chatbot_id, embedding_model, embedding_dimensions);
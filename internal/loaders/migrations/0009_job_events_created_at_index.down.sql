@@ -0,0 +1,2 @@
+-- +migrate notransaction
+DROP INDEX CONCURRENTLY IF EXISTS idx_ingestion_job_events_created_at;
@@ -0,0 +1,4 @@
+-- +migrate notransaction
+-- Lets the workers prune old progress events without scanning the table
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_ingestion_job_events_created_at
+	ON ingestion_job_events (created_at);
@@ -0,0 +1,15 @@
+-- Only succeeds while every row holds 768 dimensions; re-embed or delete the
+-- other sizes first
+ALTER TABLE embeddings ALTER COLUMN vector TYPE vector(768);
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_am WHERE amname = 'hnsw') THEN
+		CREATE INDEX IF NOT EXISTS idx_embeddings_vector ON embeddings
+			USING hnsw (vector vector_cosine_ops);
+	ELSE
+		CREATE INDEX IF NOT EXISTS idx_embeddings_vector ON embeddings
+			USING ivfflat (vector vector_cosine_ops) WITH (lists = 100);
+	END IF;
+END
+$$;
@@ -0,0 +1,9 @@
+-- Let one column hold vectors of every configured size. Each size is searched
+-- through its own partial index, idx_embeddings_vector_<n>, which the ingestor
+-- builds concurrently at startup and when a chatbot changes dimensions. The
+-- index over the whole column is never used by those searches, only adds a
+-- second HNSW write to every insert, and cannot exist on an unsized column.
+DROP INDEX IF EXISTS idx_embeddings_vector;
+
+-- Dropping the size keeps the stored values, so the table is not rewritten
+ALTER TABLE embeddings ALTER COLUMN vector TYPE vector;
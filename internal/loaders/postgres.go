@@ -2,24 +2,42 @@ package loaders
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
 	pgxvec "github.com/pgvector/pgvector-go/pgx"
+
+	"github.com/Conversly/db-ingestor/internal/types"
 )
 
+// sharedConns is the pool headroom beyond two connections per worker, one
+// running its item and one renewing its lease. It covers the webhook
+// dispatcher, queue maintenance, API requests and event streams.
+const sharedConns = 10
+
 type PostgresClient struct {
-	dsn  string
-	pool *pgxpool.Pool
+	dsn            string
+	pool           *pgxpool.Pool
+	searchLanguage string
+	// copyBatchSize is the number of embeddings staged per COPY round trip
+	copyBatchSize int
 }
 
 func NewPostgresClient(dsn string, workerCount, batchSize int) (*PostgresClient, error) {
+	if batchSize <= 0 {
+		batchSize = defaultCopyBatchSize
+	}
 	client := &PostgresClient{
-		dsn: dsn,
+		dsn:            dsn,
+		searchLanguage: DefaultSearchLanguage,
+		copyBatchSize:  batchSize,
 	}
 
 	pool, err := client.createConnectionPool(workerCount, batchSize)
@@ -40,7 +58,10 @@ func (c *PostgresClient) createConnectionPool(workerCount, batchSize int) (*pgxp
 		return nil, fmt.Errorf("failed to parse Postgres DSN: %w", err)
 	}
 
-	cfg.MaxConns = int32(workerCount) + 2
+	// pool_max_conns in the DSN takes precedence
+	if !strings.Contains(c.dsn, "pool_max_conns") {
+		cfg.MaxConns = int32(2*workerCount + sharedConns)
+	}
 	cfg.MinConns = 1
 	cfg.HealthCheckPeriod = 30 * time.Second
 	cfg.MaxConnLifetime = 60 * time.Minute
@@ -67,15 +88,7 @@ func (c *PostgresClient) createConnectionPool(workerCount, batchSize int) (*pgxp
 		return nil, fmt.Errorf("failed to ping Postgres: %w", err)
 	}
 
-	// Enable pgvector extension
-	log.Println("Enabling pgvector extension")
-	_, err = pool.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector")
-	if err != nil {
-		log.Printf("Warning: Failed to enable pgvector extension: %v", err)
-		// Don't fail here as the extension might already be enabled or user may lack permissions
-	}
-
-	log.Println("Postgres connection pool established successfully with pgvector support")
+	log.Println("Postgres connection pool established successfully")
 	return pool, nil
 }
 
@@ -94,40 +107,96 @@ func (c *PostgresClient) GetPool() *pgxpool.Pool {
 	return c.pool
 }
 
-// BatchInsertEmbeddings inserts a batch of embeddings into the database
-func (c *PostgresClient) BatchInsertEmbeddings(ctx context.Context, userID, chatbotID string, chunks []EmbeddingData) error {
+// BatchInsertEmbeddings inserts a batch of embeddings into the database. Rows
+// the database rejects are skipped and reported in the result rather than
+// failing the whole batch.
+func (c *PostgresClient) BatchInsertEmbeddings(ctx context.Context, userID, chatbotID string, chunks []EmbeddingData) (*InsertResult, error) {
 	if len(chunks) == 0 {
-		return nil
+		return &InsertResult{}, nil
 	}
 
 	// Acquire a connection from the pool to ensure pgvector types are registered
 	conn, err := c.pool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to acquire connection: %w", err)
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
 	}
 	defer conn.Release()
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	result, err := c.insertEmbeddings(ctx, tx, userID, chatbotID, chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// insertEmbeddings writes chunks through COPY when the batch is large enough
+// and row by row otherwise. The text is also indexed for keyword search with
+// the client's text search configuration. Chunks of a model the chatbot was
+// cut over from fail with ErrEmbeddingSettingsChanged, and chunks of a
+// cancelled job with ErrJobCancelled.
+func (c *PostgresClient) insertEmbeddings(ctx context.Context, tx pgx.Tx, userID, chatbotID string, chunks []EmbeddingData) (*InsertResult, error) {
+	if chunks[0].IngestionJobID != nil {
+		if err := guardJobWrite(ctx, tx, *chunks[0].IngestionJobID); err != nil {
+			return nil, err
+		}
+	}
+	if chatbotID != "" && chunks[0].Model != "" {
+		if err := guardEmbeddingWrite(ctx, tx, chatbotID, chunks[0].Model, len(chunks[0].Vector)); err != nil {
+			return nil, err
+		}
+	}
+
+	var result *InsertResult
+	var err error
+	if len(chunks) >= copyThreshold {
+		result, err = c.copyEmbeddings(ctx, tx, userID, chatbotID, chunks)
+	} else {
+		result, err = insertEmbeddingRows(ctx, tx, userID, chatbotID, c.searchLanguage, chunks, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Successfully inserted %d/%d embeddings", result.Inserted, len(chunks))
+	return result, nil
+}
+
+// insertEmbeddingRows writes chunks one INSERT at a time, each under its own
+// savepoint so a rejected row does not abort the transaction. Rejections are
+// indexed from offset, the position of chunks[0] in the caller's batch.
+func insertEmbeddingRows(ctx context.Context, tx pgx.Tx, userID, chatbotID, searchLanguage string, chunks []EmbeddingData, offset int) (*InsertResult, error) {
 	query := `
 		INSERT INTO embeddings (
 			user_id, chatbot_id, text, vector, 
-			created_at, updated_at, data_source_id, citation
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			created_at, updated_at, data_source_id, citation, ingestion_job_id, text_search,
+			metadata, topic, chunk_index, embedding_model, embedding_dimensions
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, to_tsvector($10::regconfig, $11),
+			$12::jsonb, NULLIF($13, ''), $14, NULLIF($15, ''), $16)
 	`
 
 	now := formatTimeForDB(time.Now().UTC())
-	successCount := 0
+	result := &InsertResult{}
 
-	for _, chunk := range chunks {
+	for i, chunk := range chunks {
 		// Vector is already []float32, use it directly
 		vec := pgvector.NewVector(chunk.Vector)
 
-		_, err := tx.Exec(ctx, query,
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+
+		_, err = savepoint.Exec(ctx, query,
 			userID,
 			chatbotID,
 			chunk.Text,
@@ -136,28 +205,71 @@ func (c *PostgresClient) BatchInsertEmbeddings(ctx context.Context, userID, chat
 			now,
 			chunk.DataSourceID,
 			chunk.Citation,
+			chunk.IngestionJobID,
+			searchLanguage,
+			chunk.Text,
+			encodeChunkMetadata(chunk),
+			chunk.Topic,
+			chunk.ChunkIndex,
+			chunk.Model,
+			len(chunk.Vector),
 		)
 		if err != nil {
+			if rbErr := savepoint.Rollback(ctx); rbErr != nil {
+				return nil, fmt.Errorf("failed to roll back to savepoint: %w", rbErr)
+			}
 			log.Printf("Failed to insert embedding for data_source_id=%v: %v", chunk.DataSourceID, err)
+			result.reject(offset+i, chunk, err)
 			continue
 		}
-		successCount++
+		if err := savepoint.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		result.Inserted++
 	}
+	return result, nil
+}
 
-	if successCount == 0 {
-		return fmt.Errorf("failed to insert any embeddings")
+// encodeChunkMetadata returns the chunk metadata as JSON, or nil when there is
+// none or it cannot be encoded. The chunk is kept searchable either way.
+func encodeChunkMetadata(chunk EmbeddingData) []byte {
+	if len(chunk.Metadata) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(chunk.Metadata)
+	if err != nil {
+		log.Printf("Failed to encode metadata for data_source_id=%v: %v", chunk.DataSourceID, err)
+		return nil
+	}
+	return encoded
+}
+
+// UpdateDataSourceStatus updates the status of data sources to COMPLETED
+func (c *PostgresClient) UpdateDataSourceStatus(ctx context.Context, dataSourceIDs []string, status string) error {
+	if len(dataSourceIDs) == 0 {
+		return nil
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateDataSourceStatus(ctx, tx, dataSourceIDs, status); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
-	log.Printf("Successfully inserted %d/%d embeddings", successCount, len(chunks))
 	return nil
 }
 
-// UpdateDataSourceStatus updates the status of data sources to COMPLETED
-func (c *PostgresClient) UpdateDataSourceStatus(ctx context.Context, dataSourceIDs []string, status string) error {
+// UpdateJobDataSourceStatus updates the status of data sources on behalf of
+// an ingestion job, leaving them alone once the job was cancelled so a worker
+// finishing late cannot undo the cancellation
+func (c *PostgresClient) UpdateJobDataSourceStatus(ctx context.Context, ingestionJobID string, dataSourceIDs []string, status string) error {
 	if len(dataSourceIDs) == 0 {
 		return nil
 	}
@@ -168,6 +280,24 @@ func (c *PostgresClient) UpdateDataSourceStatus(ctx context.Context, dataSourceI
 	}
 	defer tx.Rollback(ctx)
 
+	if err := guardJobWrite(ctx, tx, ingestionJobID); errors.Is(err, ErrJobCancelled) {
+		log.Printf("Left %d data sources of cancelled job %s as they are instead of %s", len(dataSourceIDs), ingestionJobID, status)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := updateDataSourceStatus(ctx, tx, dataSourceIDs, status); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func updateDataSourceStatus(ctx context.Context, tx pgx.Tx, dataSourceIDs []string, status string) error {
 	query := `
 		UPDATE data_source 
 		SET status = $1, updated_at = $2
@@ -180,15 +310,56 @@ func (c *PostgresClient) UpdateDataSourceStatus(ctx context.Context, dataSourceI
 		return fmt.Errorf("failed to update data source status: %w", err)
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	rowsAffected := result.RowsAffected()
 	log.Printf("Updated status to '%s' for %d data sources", status, rowsAffected)
 	return nil
 }
 
+// guardJobWrite fails with ErrJobCancelled when the ingestion job was
+// cancelled. The job row stays share-locked until the transaction ends, so a
+// concurrent cancellation waits for the write and cleans up after it.
+func guardJobWrite(ctx context.Context, tx pgx.Tx, ingestionJobID string) error {
+	if ingestionJobID == "" {
+		return nil
+	}
+	var status string
+	err := tx.QueryRow(ctx, `SELECT status FROM ingestion_jobs WHERE id = $1 FOR SHARE`, ingestionJobID).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read ingestion job: %w", err)
+	}
+	if status == string(types.StatusCancelled) {
+		return ErrJobCancelled
+	}
+	return nil
+}
+
+// InsertResult reports which embeddings of a batch were stored
+type InsertResult struct {
+	Inserted int
+	Rejected []RejectedEmbedding
+	// Removed counts the previous generation's rows deleted by ReplaceEmbeddings
+	Removed int64
+}
+
+// RejectedEmbedding is a chunk the database refused to store
+type RejectedEmbedding struct {
+	Index        int    `json:"index"` // position in the batch passed in
+	DataSourceID string `json:"datasourceId,omitempty"`
+	ChunkIndex   *int   `json:"chunkIndex,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+func (r *InsertResult) reject(index int, chunk EmbeddingData, err error) {
+	rejected := RejectedEmbedding{Index: index, ChunkIndex: chunk.ChunkIndex, Reason: err.Error()}
+	if chunk.DataSourceID != nil {
+		rejected.DataSourceID = *chunk.DataSourceID
+	}
+	r.Rejected = append(r.Rejected, rejected)
+}
+
 // EmbeddingData represents the data needed to insert an embedding
 type EmbeddingData struct {
 	Topic        string
@@ -196,4 +367,14 @@ type EmbeddingData struct {
 	Vector       []float32
 	DataSourceID *string
 	Citation     *string
+	// IngestionJobID tags the row with the job that wrote it, so a replacing job
+	// can tell its own rows from the previous generation
+	IngestionJobID *string
+	// Metadata is the chunk metadata built by the processor (headers, row,
+	// page, question...), stored as JSONB
+	Metadata   map[string]interface{}
+	ChunkIndex *int
+	// Model is the ModelID of the embedder that produced Vector; the row is
+	// tagged with it and the vector's dimensions
+	Model string
 }
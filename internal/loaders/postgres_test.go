@@ -0,0 +1,106 @@
+package loaders
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// testClient connects to the migrated database in TEST_DATABASE_URL and skips
+// the test when it is not set
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/loaders
+func testClient(tb testing.TB, copyBatchSize int) *PostgresClient {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	client, err := NewPostgresClient(dsn, 1, copyBatchSize)
+	if err != nil {
+		tb.Fatalf("NewPostgresClient() error = %v", err)
+	}
+	tb.Cleanup(func() { client.Close() })
+	return client
+}
+
+// TestInsertEmbeddingsRejectsRows checks that rows the database refuses are
+// reported and skipped without aborting the transaction, on both insert
+// paths. Every case is rolled back.
+func TestInsertEmbeddingsRejectsRows(t *testing.T) {
+	client := testClient(t, 20)
+	ctx := context.Background()
+	const chatbotID = "test-rejects-chatbot"
+
+	paths := []struct {
+		name   string
+		insert func(ctx context.Context, tx pgx.Tx, chunks []EmbeddingData) (*InsertResult, error)
+	}{
+		{name: "rows", insert: func(ctx context.Context, tx pgx.Tx, chunks []EmbeddingData) (*InsertResult, error) {
+			return insertEmbeddingRows(ctx, tx, "test-user", chatbotID, client.searchLanguage, chunks, 0)
+		}},
+		{name: "copy", insert: func(ctx context.Context, tx pgx.Tx, chunks []EmbeddingData) (*InsertResult, error) {
+			return client.copyEmbeddings(ctx, tx, "test-user", chatbotID, chunks)
+		}},
+	}
+
+	tests := []struct {
+		name string
+		size int
+		bad  []int // chunks the database refuses
+	}{
+		{name: "all valid", size: 10},
+		{name: "one bad row", size: 10, bad: []int{4}},
+		{name: "first and last rows", size: 10, bad: []int{0, 9}},
+		{name: "bad rows in a later copy batch", size: 50, bad: []int{23, 37}},
+		{name: "every row", size: 3, bad: []int{0, 1, 2}},
+	}
+
+	for _, path := range paths {
+		for _, tt := range tests {
+			t.Run(path.name+"/"+tt.name, func(t *testing.T) {
+				chunks := benchmarkChunks(tt.size, 768)
+				for _, i := range tt.bad {
+					chunks[i].Text = "nul \x00 byte"
+				}
+
+				tx, err := client.pool.Begin(ctx)
+				if err != nil {
+					t.Fatalf("Begin() error = %v", err)
+				}
+				defer tx.Rollback(ctx)
+
+				result, err := path.insert(ctx, tx, chunks)
+				if err != nil {
+					t.Fatalf("insert error = %v", err)
+				}
+				if want := tt.size - len(tt.bad); result.Inserted != want {
+					t.Errorf("Inserted = %d, want %d", result.Inserted, want)
+				}
+				var rejected []int
+				for _, r := range result.Rejected {
+					rejected = append(rejected, r.Index)
+					if r.Reason == "" {
+						t.Errorf("rejection of chunk %d has no reason", r.Index)
+					}
+				}
+				if !slices.Equal(rejected, tt.bad) {
+					t.Errorf("rejected = %v, want %v", rejected, tt.bad)
+				}
+
+				// The transaction must still be usable and hold the good rows
+				var stored int
+				if err := tx.QueryRow(ctx, `SELECT count(*) FROM embeddings WHERE chatbot_id = $1`, chatbotID).Scan(&stored); err != nil {
+					t.Fatalf("transaction unusable after rejections: %v", err)
+				}
+				if stored != result.Inserted {
+					t.Errorf("stored %d rows, want %d", stored, result.Inserted)
+				}
+			})
+		}
+	}
+}
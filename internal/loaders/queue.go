@@ -0,0 +1,245 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Work queue item states
+const (
+	WorkStatusQueued  = "queued"
+	WorkStatusRunning = "running"
+	WorkStatusDead    = "dead"
+)
+
+// WorkItem is a row claimed from the work queue
+type WorkItem struct {
+	ID             int64
+	Kind           string
+	JobID          string
+	IngestionJobID string
+	Payload        []byte
+	Attempts       int
+	MaxAttempts    int
+	LastError      string
+	Cancelled      bool // the owning ingestion job was cancelled
+}
+
+// NewWork is an item enqueued in the same transaction as other changes
+type NewWork struct {
+	Kind        string
+	JobID       string
+	Payload     []byte
+	MaxAttempts int
+}
+
+// EnqueueWork inserts a new item that becomes claimable immediately
+func (c *PostgresClient) EnqueueWork(ctx context.Context, kind, jobID, ingestionJobID string, payload []byte, maxAttempts int) error {
+	return enqueueWork(ctx, c.pool, kind, jobID, ingestionJobID, payload, maxAttempts)
+}
+
+// execer runs statements on the pool or inside a transaction
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func enqueueWork(ctx context.Context, db execer, kind, jobID, ingestionJobID string, payload []byte, maxAttempts int) error {
+	query := `
+		INSERT INTO work_queue (kind, job_id, ingestion_job_id, payload, max_attempts)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+	`
+	if _, err := db.Exec(ctx, query, kind, jobID, ingestionJobID, payload, maxAttempts); err != nil {
+		return fmt.Errorf("failed to enqueue work: %w", err)
+	}
+	return nil
+}
+
+// ClaimWork leases the oldest available item to owner. Items whose lease has
+// expired (their worker crashed or was redeployed) are claimable again until
+// they run out of attempts. Returns nil when the queue is empty.
+func (c *PostgresClient) ClaimWork(ctx context.Context, owner string, lease time.Duration) (*WorkItem, error) {
+	query := `
+		UPDATE work_queue
+		SET status = $1,
+			attempts = attempts + 1,
+			lease_owner = $2,
+			lease_expires_at = now() + make_interval(secs => $3),
+			updated_at = now()
+		WHERE id = (
+			SELECT id FROM work_queue
+			WHERE (status = $4 AND available_at <= now())
+				OR (status = $1 AND lease_expires_at < now() AND attempts < max_attempts)
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, kind, job_id, COALESCE(ingestion_job_id, ''), payload,
+			attempts, max_attempts, COALESCE(last_error, ''),
+			EXISTS (
+				SELECT 1 FROM ingestion_jobs j
+				WHERE j.id = work_queue.ingestion_job_id AND j.status = $5
+			)
+	`
+
+	var item WorkItem
+	err := c.pool.QueryRow(ctx, query, WorkStatusRunning, owner, lease.Seconds(), WorkStatusQueued, string(types.StatusCancelled)).Scan(
+		&item.ID,
+		&item.Kind,
+		&item.JobID,
+		&item.IngestionJobID,
+		&item.Payload,
+		&item.Attempts,
+		&item.MaxAttempts,
+		&item.LastError,
+		&item.Cancelled,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim work: %w", err)
+	}
+	return &item, nil
+}
+
+// ExtendWorkLease is the heartbeat of a running item. It reports whether the
+// lease is still held and whether the owning ingestion job was cancelled.
+func (c *PostgresClient) ExtendWorkLease(ctx context.Context, id int64, owner string, lease time.Duration) (owned bool, cancelled bool, err error) {
+	query := `
+		UPDATE work_queue
+		SET lease_expires_at = now() + make_interval(secs => $3), updated_at = now()
+		WHERE id = $1 AND lease_owner = $2 AND status = $4
+		RETURNING EXISTS (
+			SELECT 1 FROM ingestion_jobs j
+			WHERE j.id = work_queue.ingestion_job_id AND j.status = $5
+		)
+	`
+	err = c.pool.QueryRow(ctx, query, id, owner, lease.Seconds(), WorkStatusRunning, string(types.StatusCancelled)).Scan(&cancelled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to extend work lease: %w", err)
+	}
+	return true, cancelled, nil
+}
+
+// DeleteQueuedWork drops items of an ingestion job that no worker has claimed yet
+func (c *PostgresClient) DeleteQueuedWork(ctx context.Context, ingestionJobID string) (int64, error) {
+	query := `DELETE FROM work_queue WHERE ingestion_job_id = $1 AND status = $2`
+	result, err := c.pool.Exec(ctx, query, ingestionJobID, WorkStatusQueued)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete queued work: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// QueuedUploadFiles returns the upload spool files referenced by ingestion
+// items that are still queued or running
+func (c *PostgresClient) QueuedUploadFiles(ctx context.Context, kind string) ([]string, error) {
+	query := `
+		SELECT DISTINCT upload->>'spoolFile'
+		FROM work_queue,
+			jsonb_array_elements(COALESCE(payload->'request'->'uploads', '[]'::jsonb)) AS upload
+		WHERE kind = $1 AND status IN ($2, $3) AND upload->>'spoolFile' IS NOT NULL
+	`
+	rows, err := c.pool.Query(ctx, query, kind, WorkStatusQueued, WorkStatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queued uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var file string
+		if err := rows.Scan(&file); err != nil {
+			return nil, fmt.Errorf("failed to scan queued upload: %w", err)
+		}
+		files = append(files, file)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list queued uploads: %w", err)
+	}
+	return files, nil
+}
+
+// CompleteWork removes a finished item from the queue
+func (c *PostgresClient) CompleteWork(ctx context.Context, id int64, owner string) error {
+	query := `DELETE FROM work_queue WHERE id = $1 AND lease_owner = $2`
+	if _, err := c.pool.Exec(ctx, query, id, owner); err != nil {
+		return fmt.Errorf("failed to complete work: %w", err)
+	}
+	return nil
+}
+
+// FailWork moves an item that can never succeed to the dead state for inspection
+func (c *PostgresClient) FailWork(ctx context.Context, id int64, owner, errorMessage string) error {
+	query := `
+		UPDATE work_queue
+		SET status = $3, last_error = $4, lease_expires_at = NULL, updated_at = now()
+		WHERE id = $1 AND lease_owner = $2
+	`
+	if _, err := c.pool.Exec(ctx, query, id, owner, WorkStatusDead, errorMessage); err != nil {
+		return fmt.Errorf("failed to fail work: %w", err)
+	}
+	return nil
+}
+
+// PruneDeadWork deletes dead items that have been kept for inspection longer
+// than maxAge
+func (c *PostgresClient) PruneDeadWork(ctx context.Context, maxAge time.Duration) (int64, error) {
+	result, err := c.pool.Exec(ctx, `
+		DELETE FROM work_queue WHERE status = $1 AND updated_at < now() - make_interval(secs => $2)
+	`, WorkStatusDead, maxAge.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune dead work: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeadLetterExpiredWork moves items whose lease expired on their final attempt
+// to the dead state and returns them so the caller can record the failure
+func (c *PostgresClient) DeadLetterExpiredWork(ctx context.Context) ([]WorkItem, error) {
+	query := `
+		UPDATE work_queue
+		SET status = $1,
+			last_error = 'lease expired after max attempts',
+			lease_expires_at = NULL,
+			updated_at = now()
+		WHERE id IN (
+			SELECT id FROM work_queue
+			WHERE status = $2 AND lease_expires_at < now() AND attempts >= max_attempts
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, job_id, COALESCE(ingestion_job_id, ''), payload, attempts, max_attempts
+	`
+	rows, err := c.pool.Query(ctx, query, WorkStatusDead, WorkStatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dead-letter expired work: %w", err)
+	}
+	defer rows.Close()
+
+	var items []WorkItem
+	for rows.Next() {
+		var item WorkItem
+		if err := rows.Scan(
+			&item.ID,
+			&item.Kind,
+			&item.JobID,
+			&item.IngestionJobID,
+			&item.Payload,
+			&item.Attempts,
+			&item.MaxAttempts,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan work item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
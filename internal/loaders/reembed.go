@@ -0,0 +1,484 @@
+package loaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+var (
+	// ErrReembedIncomplete is returned by CutoverReembedRun while rows of the
+	// run's scope have no re-embedded vector yet
+	ErrReembedIncomplete = errors.New("re-embedding run has rows left to embed")
+	// ErrReembedState is returned when a run is not in the state an operation needs
+	ErrReembedState = errors.New("re-embedding run is in the wrong state")
+	// ErrEmbeddingSettingsChanged is returned when rows embedded with a chatbot's
+	// previous settings are written after a re-embedding run cut it over
+	ErrEmbeddingSettingsChanged = errors.New("chatbot embedding settings changed by a re-embedding cutover")
+)
+
+// embeddingsLockClass namespaces the per-chatbot advisory locks that order
+// embedding writes, taken shared, against cutovers and rollbacks, taken
+// exclusively
+const embeddingsLockClass = 7305
+
+// ReembedRow is an embedding waiting to be re-embedded
+type ReembedRow struct {
+	ID   int64
+	Text string
+}
+
+// ReembedRollback reports the rows a rollback restored, and those written with
+// the run's settings after cutover, which have no previous vector to restore
+type ReembedRollback struct {
+	Reverted int64
+	Stranded int64
+}
+
+const reembedRunColumns = `
+	id, COALESCE(chatbot_id, ''), COALESCE(model, ''), COALESCE(task_type, ''), COALESCE(dimensions, 0),
+	model_id, target_dimensions, status, total_rows, embedded_rows, last_embedding_id,
+	COALESCE(error_message, ''), created_at, updated_at, cutover_at, rolled_back_at
+`
+
+// reembedScope selects the rows of a run that are not on its target settings
+// yet; $1 is the chatbot ID or empty for every chatbot, $2 the target model ID
+// and $3 the target dimensions
+const reembedScope = `
+	($1 = '' OR e.chatbot_id = $1)
+	AND e.text <> ''
+	AND (e.embedding_model IS DISTINCT FROM $2 OR e.embedding_dimensions IS DISTINCT FROM $3)
+`
+
+// reembedPending selects the rows of the scope that have no vector from run $4
+// yet. Rows are found by what is missing rather than by a cursor, so rows
+// committed late with a lower id are not skipped.
+const reembedPending = reembedScope + `
+	AND NOT EXISTS (SELECT 1 FROM reembed_vectors r WHERE r.run_id = $4 AND r.embedding_id = e.id)
+`
+
+func scanReembedRun(row pgx.Row) (*types.ReembedRun, error) {
+	var run types.ReembedRun
+	var status string
+	err := row.Scan(
+		&run.ID,
+		&run.ChatbotID,
+		&run.Model,
+		&run.TaskType,
+		&run.Dimensions,
+		&run.ModelID,
+		&run.TargetDimensions,
+		&status,
+		&run.TotalRows,
+		&run.EmbeddedRows,
+		&run.LastEmbeddingID,
+		&run.ErrorMessage,
+		&run.CreatedAt,
+		&run.UpdatedAt,
+		&run.CutoverAt,
+		&run.RolledBackAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load re-embedding run: %w", err)
+	}
+	run.Status = types.ReembedStatus(status)
+	return &run, nil
+}
+
+// CreateReembedRun stores a new run in the running state
+func (c *PostgresClient) CreateReembedRun(ctx context.Context, run types.ReembedRun) error {
+	_, err := c.pool.Exec(ctx, `
+		INSERT INTO reembed_runs (id, chatbot_id, model, task_type, dimensions, model_id, target_dimensions, status)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, 0), $6, $7, $8)
+	`, run.ID, run.ChatbotID, run.Model, run.TaskType, run.Dimensions, run.ModelID, run.TargetDimensions, string(types.ReembedStatusRunning))
+	if err != nil {
+		return fmt.Errorf("failed to create re-embedding run: %w", err)
+	}
+	return nil
+}
+
+// GetReembedRun loads a run. Returns ErrNotFound if there is none.
+func (c *PostgresClient) GetReembedRun(ctx context.Context, runID string) (*types.ReembedRun, error) {
+	return scanReembedRun(c.pool.QueryRow(ctx, `SELECT `+reembedRunColumns+` FROM reembed_runs WHERE id = $1`, runID))
+}
+
+// ListReembedRuns returns every run, newest first
+func (c *PostgresClient) ListReembedRuns(ctx context.Context) ([]types.ReembedRun, error) {
+	rows, err := c.pool.Query(ctx, `SELECT `+reembedRunColumns+` FROM reembed_runs ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list re-embedding runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []types.ReembedRun
+	for rows.Next() {
+		run, err := scanReembedRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list re-embedding runs: %w", err)
+	}
+	return runs, nil
+}
+
+// ResumeReembedRun puts a running, ready or failed run back into the running
+// state and re-estimates its total from the rows it has not embedded yet
+func (c *PostgresClient) ResumeReembedRun(ctx context.Context, runID string) (*types.ReembedRun, error) {
+	run, err := c.GetReembedRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	switch run.Status {
+	case types.ReembedStatusRunning, types.ReembedStatusReady, types.ReembedStatusFailed:
+	default:
+		return nil, fmt.Errorf("%w: run %s is %s", ErrReembedState, runID, run.Status)
+	}
+
+	return scanReembedRun(c.pool.QueryRow(ctx, `
+		UPDATE reembed_runs r
+		SET status = $5,
+			error_message = NULL,
+			total_rows = r.embedded_rows + (
+				SELECT count(*) FROM embeddings e WHERE `+reembedPending+`
+			),
+			updated_at = now()
+		WHERE r.id = $4
+		RETURNING `+reembedRunColumns,
+		run.ChatbotID, run.ModelID, run.TargetDimensions, runID, string(types.ReembedStatusRunning)))
+}
+
+// NextReembedBatch returns up to limit rows of the run's scope it has not
+// embedded yet
+func (c *PostgresClient) NextReembedBatch(ctx context.Context, run *types.ReembedRun, limit int) ([]ReembedRow, error) {
+	rows, err := c.pool.Query(ctx, `
+		SELECT e.id, e.text FROM embeddings e
+		WHERE `+reembedPending+`
+		ORDER BY e.id
+		LIMIT $5
+	`, run.ChatbotID, run.ModelID, run.TargetDimensions, run.ID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows to re-embed: %w", err)
+	}
+	batch, err := pgx.CollectRows(rows, pgx.RowToStructByPos[ReembedRow])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows to re-embed: %w", err)
+	}
+	return batch, nil
+}
+
+// SaveReembedBatch stores the new vectors of a batch in the shadow table and
+// counts them in the run's progress, in one transaction so an interrupted run
+// resumes exactly where it stopped
+func (c *PostgresClient) SaveReembedBatch(ctx context.Context, run *types.ReembedRun, rows []ReembedRow, vectors [][]float32) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if len(vectors) != len(rows) {
+		return fmt.Errorf("got %d vectors for %d rows", len(vectors), len(rows))
+	}
+
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for i, row := range rows {
+		batch.Queue(`
+			INSERT INTO reembed_vectors (run_id, embedding_id, vector)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (run_id, embedding_id) DO UPDATE SET vector = EXCLUDED.vector
+		`, run.ID, row.ID, pgvector.NewVector(vectors[i]))
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to store re-embedded vectors: %w", err)
+	}
+
+	last := rows[len(rows)-1].ID
+	if _, err := tx.Exec(ctx, `
+		UPDATE reembed_runs
+		SET embedded_rows = embedded_rows + $2, last_embedding_id = $3, updated_at = now()
+		WHERE id = $1
+	`, run.ID, len(rows), last); err != nil {
+		return fmt.Errorf("failed to advance re-embedding run: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	run.EmbeddedRows += len(rows)
+	run.LastEmbeddingID = last
+	return nil
+}
+
+// SetReembedRunStatus records a run's new state and error, if any
+func (c *PostgresClient) SetReembedRunStatus(ctx context.Context, runID string, status types.ReembedStatus, errorMessage string) error {
+	_, err := c.pool.Exec(ctx, `
+		UPDATE reembed_runs SET status = $2, error_message = NULLIF($3, ''), updated_at = now()
+		WHERE id = $1
+	`, runID, string(status), errorMessage)
+	if err != nil {
+		return fmt.Errorf("failed to update re-embedding run: %w", err)
+	}
+	return nil
+}
+
+// CutoverReembedRun atomically swaps a ready run's vectors into embeddings,
+// keeping the replaced ones for rollback, and points the affected chatbots at
+// the run's settings. Writes of those chatbots wait for the duration so no row
+// slips in between the completeness check and the swap; other chatbots and
+// searches do not. Returns ErrReembedIncomplete when rows were added since the
+// run last caught up.
+func (c *PostgresClient) CutoverReembedRun(ctx context.Context, runID string) (*types.ReembedRun, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	run, err := scanReembedRun(tx.QueryRow(ctx, `SELECT `+reembedRunColumns+` FROM reembed_runs WHERE id = $1 FOR UPDATE`, runID))
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != types.ReembedStatusReady {
+		return nil, fmt.Errorf("%w: run %s is %s, not %s", ErrReembedState, runID, run.Status, types.ReembedStatusReady)
+	}
+
+	// Chatbots with no re-embedded row keep their settings and are not locked
+	chatbots := []string{run.ChatbotID}
+	if run.ChatbotID == "" {
+		rows, err := tx.Query(ctx, `
+			SELECT DISTINCT e.chatbot_id FROM embeddings e
+			JOIN reembed_vectors r ON r.embedding_id = e.id AND r.run_id = $1
+			WHERE e.chatbot_id IS NOT NULL
+		`, runID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list re-embedded chatbots: %w", err)
+		}
+		chatbots, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return nil, fmt.Errorf("failed to list re-embedded chatbots: %w", err)
+		}
+	}
+	if err := lockChatbotEmbeddings(ctx, tx, chatbots); err != nil {
+		return nil, err
+	}
+
+	var missing int
+	err = tx.QueryRow(ctx, `
+		SELECT count(*) FROM embeddings e
+		WHERE `+reembedPending+` AND e.chatbot_id = ANY($5)
+	`, run.ChatbotID, run.ModelID, run.TargetDimensions, runID, chatbots).Scan(&missing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check re-embedding run: %w", err)
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("%w: %d rows", ErrReembedIncomplete, missing)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE reembed_vectors r
+		SET previous_vector = e.vector,
+			previous_model = e.embedding_model,
+			previous_dimensions = e.embedding_dimensions
+		FROM embeddings e
+		WHERE r.run_id = $1 AND e.id = r.embedding_id
+	`, runID); err != nil {
+		return nil, fmt.Errorf("failed to keep replaced vectors: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE embeddings e
+		SET vector = r.vector, embedding_model = $2, embedding_dimensions = $3, updated_at = now()
+		FROM reembed_vectors r
+		WHERE r.run_id = $1 AND e.id = r.embedding_id
+	`, runID, run.ModelID, run.TargetDimensions); err != nil {
+		return nil, fmt.Errorf("failed to swap in re-embedded vectors: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO reembed_run_chatbots (run_id, chatbot_id, had_override, previous_model, previous_task_type, previous_dimensions)
+		SELECT $1, b.chatbot_id, s.chatbot_id IS NOT NULL, s.model, s.task_type, s.dimensions
+		FROM (
+			SELECT DISTINCT e.chatbot_id FROM embeddings e
+			JOIN reembed_vectors r ON r.embedding_id = e.id AND r.run_id = $1
+			UNION
+			SELECT NULLIF($2, '')
+		) b
+		LEFT JOIN chatbot_embedding_settings s ON s.chatbot_id = b.chatbot_id
+		WHERE b.chatbot_id IS NOT NULL
+		ON CONFLICT DO NOTHING
+	`, runID, run.ChatbotID); err != nil {
+		return nil, fmt.Errorf("failed to record chatbot settings: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO chatbot_embedding_settings (chatbot_id, model, task_type, dimensions)
+		SELECT chatbot_id, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, 0)
+		FROM reembed_run_chatbots WHERE run_id = $1
+		ON CONFLICT (chatbot_id) DO UPDATE
+		SET model = EXCLUDED.model,
+			task_type = EXCLUDED.task_type,
+			dimensions = EXCLUDED.dimensions,
+			updated_at = now()
+	`, runID, run.Model, run.TaskType, run.Dimensions); err != nil {
+		return nil, fmt.Errorf("failed to switch chatbot settings: %w", err)
+	}
+
+	run, err = scanReembedRun(tx.QueryRow(ctx, `
+		UPDATE reembed_runs SET status = $2, cutover_at = now(), updated_at = now()
+		WHERE id = $1
+		RETURNING `+reembedRunColumns, runID, string(types.ReembedStatusActive)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return run, nil
+}
+
+// RollbackReembedRun restores the vectors and chatbot settings an active run
+// replaced at cutover
+func (c *PostgresClient) RollbackReembedRun(ctx context.Context, runID string) (*ReembedRollback, error) {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	run, err := scanReembedRun(tx.QueryRow(ctx, `SELECT `+reembedRunColumns+` FROM reembed_runs WHERE id = $1 FOR UPDATE`, runID))
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != types.ReembedStatusActive {
+		return nil, fmt.Errorf("%w: run %s is %s, not %s", ErrReembedState, runID, run.Status, types.ReembedStatusActive)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT chatbot_id FROM reembed_run_chatbots WHERE run_id = $1`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list re-embedded chatbots: %w", err)
+	}
+	chatbots, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list re-embedded chatbots: %w", err)
+	}
+	if err := lockChatbotEmbeddings(ctx, tx, chatbots); err != nil {
+		return nil, err
+	}
+
+	reverted, err := tx.Exec(ctx, `
+		UPDATE embeddings e
+		SET vector = r.previous_vector,
+			embedding_model = r.previous_model,
+			embedding_dimensions = r.previous_dimensions,
+			updated_at = now()
+		FROM reembed_vectors r
+		WHERE r.run_id = $1 AND e.id = r.embedding_id AND r.previous_vector IS NOT NULL
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore replaced vectors: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM chatbot_embedding_settings s
+		USING reembed_run_chatbots b
+		WHERE b.run_id = $1 AND b.chatbot_id = s.chatbot_id AND NOT b.had_override
+	`, runID); err != nil {
+		return nil, fmt.Errorf("failed to restore chatbot settings: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO chatbot_embedding_settings (chatbot_id, model, task_type, dimensions)
+		SELECT chatbot_id, previous_model, previous_task_type, previous_dimensions
+		FROM reembed_run_chatbots WHERE run_id = $1 AND had_override
+		ON CONFLICT (chatbot_id) DO UPDATE
+		SET model = EXCLUDED.model,
+			task_type = EXCLUDED.task_type,
+			dimensions = EXCLUDED.dimensions,
+			updated_at = now()
+	`, runID); err != nil {
+		return nil, fmt.Errorf("failed to restore chatbot settings: %w", err)
+	}
+
+	var stranded int64
+	err = tx.QueryRow(ctx, `
+		SELECT count(*) FROM embeddings e
+		JOIN reembed_run_chatbots b ON b.run_id = $1 AND b.chatbot_id = e.chatbot_id
+		WHERE e.embedding_model = $2 AND e.embedding_dimensions = $3
+	`, runID, run.ModelID, run.TargetDimensions).Scan(&stranded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count rows left on the run's settings: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE reembed_runs SET status = $2, rolled_back_at = now(), updated_at = now()
+		WHERE id = $1
+	`, runID, string(types.ReembedStatusRolledBack)); err != nil {
+		return nil, fmt.Errorf("failed to update re-embedding run: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &ReembedRollback{Reverted: reverted.RowsAffected(), Stranded: stranded}, nil
+}
+
+// lockChatbotEmbeddings blocks embedding writes of the chatbots until tx ends.
+// Locks are taken in order so concurrent cutovers cannot deadlock.
+func lockChatbotEmbeddings(ctx context.Context, tx pgx.Tx, chatbots []string) error {
+	_, err := tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock($1, hashtext(chatbot_id))
+		FROM (SELECT DISTINCT unnest($2::text[]) AS chatbot_id ORDER BY 1) c
+	`, embeddingsLockClass, chatbots)
+	if err != nil {
+		return fmt.Errorf("failed to lock chatbot embeddings: %w", err)
+	}
+	return nil
+}
+
+// guardEmbeddingWrite holds off cutovers and rollbacks of the chatbot until tx
+// ends and fails with ErrEmbeddingSettingsChanged when the chatbot's active
+// run targets another model or dimensionality than the rows being written.
+// Workers that resolved the chatbot's settings before a cutover re-embed
+// instead of leaving old vectors next to the new ones.
+func guardEmbeddingWrite(ctx context.Context, tx pgx.Tx, chatbotID, modelID string, dimensions int) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock_shared($1, hashtext($2))`, embeddingsLockClass, chatbotID); err != nil {
+		return fmt.Errorf("failed to lock chatbot embeddings: %w", err)
+	}
+
+	// Settings changed after the cutover are the chatbot owner's, not the run's
+	var runModelID string
+	var runDimensions int
+	err := tx.QueryRow(ctx, `
+		SELECT r.model_id, r.target_dimensions
+		FROM reembed_runs r
+		JOIN reembed_run_chatbots b ON b.run_id = r.id
+		JOIN chatbot_embedding_settings s ON s.chatbot_id = b.chatbot_id
+		WHERE b.chatbot_id = $1 AND r.status = $2 AND s.updated_at <= r.cutover_at
+		ORDER BY r.cutover_at DESC
+		LIMIT 1
+	`, chatbotID, string(types.ReembedStatusActive)).Scan(&runModelID, &runDimensions)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check chatbot embedding settings: %w", err)
+	}
+	if runModelID != modelID || runDimensions != dimensions {
+		return fmt.Errorf("%w: rows use %s with %d dimensions, chatbot %s now uses %s with %d",
+			ErrEmbeddingSettingsChanged, modelID, dimensions, chatbotID, runModelID, runDimensions)
+	}
+	return nil
+}
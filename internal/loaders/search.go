@@ -0,0 +1,225 @@
+package loaders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/pgvector/pgvector-go"
+)
+
+// rrfK dampens the advantage of top ranks in reciprocal rank fusion; 60 is the
+// value from the original RRF paper
+const rrfK = 60
+
+// HybridWeights scales each ranking's contribution to the fused score
+type HybridWeights struct {
+	Vector  float64
+	Keyword float64
+}
+
+// SearchFilter narrows the chunks a search considers
+type SearchFilter struct {
+	DatasourceIDs []string
+	// Metadata keeps only chunks whose stored metadata contains every given
+	// key with the given value
+	Metadata map[string]interface{}
+}
+
+// args returns the datasource and metadata containment query parameters
+func (f SearchFilter) args() ([]string, []byte, error) {
+	var metadata []byte
+	if len(f.Metadata) > 0 {
+		encoded, err := json.Marshal(f.Metadata)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode metadata filter: %w", err)
+		}
+		metadata = encoded
+	}
+	return nonNil(f.DatasourceIDs), metadata, nil
+}
+
+// SearchEmbeddings returns the topK chunks of a chatbot closest to vector by
+// cosine distance, optionally limited to some datasources or metadata. Only
+// chunks embedded by model with the vector's dimensions are compared.
+func (c *PostgresClient) SearchEmbeddings(ctx context.Context, chatbotID, model string, vector []float32, topK int, filter SearchFilter) ([]types.SearchResult, error) {
+	query := fmt.Sprintf(`
+		SELECT id::text, text, COALESCE(citation, ''), COALESCE(data_source_id::text, ''),
+			1 - (%[1]s) AS score,
+			COALESCE(ingestion_job_id, ''), created_at,
+			metadata, COALESCE(topic, ''), chunk_index
+		FROM embeddings
+		WHERE chatbot_id = $2
+			AND embedding_model = $6 AND embedding_dimensions = %[2]d
+			AND (cardinality($3::text[]) = 0 OR data_source_id::text = ANY($3))
+			AND ($5::jsonb IS NULL OR metadata @> $5)
+		ORDER BY %[1]s
+		LIMIT $4
+	`, vectorDistance("vector", len(vector)), len(vector))
+	datasourceIDs, metadataFilter, err := filter.args()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.pool.Query(ctx, query, pgvector.NewVector(vector), chatbotID, datasourceIDs, topK, metadataFilter, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSearchResults(rows, false)
+}
+
+// HybridSearchEmbeddings ranks the chatbot's chunks by vector distance and by
+// full-text rank of queryText separately, then fuses both rankings with
+// weighted reciprocal rank fusion. Each ranking contributes its best
+// candidates, so chunks matching only the keywords can still surface. Both
+// rankings only consider chunks embedded by model with the vector's dimensions.
+func (c *PostgresClient) HybridSearchEmbeddings(ctx context.Context, chatbotID, model string, vector []float32, queryText string, topK int, filter SearchFilter, weights HybridWeights) ([]types.SearchResult, error) {
+	query := fmt.Sprintf(`
+		WITH vector_hits AS (
+			SELECT id, row_number() OVER (ORDER BY %[1]s) AS rank
+			FROM embeddings
+			WHERE chatbot_id = $2
+				AND embedding_model = $12 AND embedding_dimensions = %[3]d
+				AND (cardinality($3::text[]) = 0 OR data_source_id::text = ANY($3))
+				AND ($11::jsonb IS NULL OR metadata @> $11)
+			ORDER BY %[1]s
+			LIMIT $5
+		),
+		keyword_hits AS (
+			SELECT id, row_number() OVER (ORDER BY ts_rank_cd(text_search, q) DESC) AS rank
+			FROM embeddings, websearch_to_tsquery($6::regconfig, $4) AS q
+			WHERE chatbot_id = $2
+				AND embedding_model = $12 AND embedding_dimensions = %[3]d
+				AND (cardinality($3::text[]) = 0 OR data_source_id::text = ANY($3))
+				AND ($11::jsonb IS NULL OR metadata @> $11)
+				AND text_search @@ q
+			ORDER BY ts_rank_cd(text_search, q) DESC
+			LIMIT $5
+		),
+		fused AS (
+			SELECT COALESCE(v.id, k.id) AS id,
+				COALESCE($7::float8 / ($9::float8 + v.rank), 0) + COALESCE($8::float8 / ($9::float8 + k.rank), 0) AS score,
+				COALESCE(v.rank, 0) AS vector_rank,
+				COALESCE(k.rank, 0) AS keyword_rank
+			FROM vector_hits v
+			FULL OUTER JOIN keyword_hits k ON k.id = v.id
+		)
+		SELECT e.id::text, e.text, COALESCE(e.citation, ''), COALESCE(e.data_source_id::text, ''),
+			f.score,
+			COALESCE(e.ingestion_job_id, ''), e.created_at,
+			e.metadata, COALESCE(e.topic, ''), e.chunk_index,
+			1 - (%[2]s), f.vector_rank, f.keyword_rank
+		FROM fused f
+		JOIN embeddings e ON e.id = f.id
+		ORDER BY f.score DESC
+		LIMIT $10
+	`, vectorDistance("vector", len(vector)), vectorDistance("e.vector", len(vector)), len(vector))
+	candidates := topK * 5
+	if candidates < 50 {
+		candidates = 50
+	}
+
+	datasourceIDs, metadataFilter, err := filter.args()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.pool.Query(ctx, query,
+		pgvector.NewVector(vector),
+		chatbotID,
+		datasourceIDs,
+		queryText,
+		candidates,
+		c.searchLanguage,
+		weights.Vector,
+		weights.Keyword,
+		float64(rrfK),
+		topK,
+		metadataFilter,
+		model,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run hybrid search: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSearchResults(rows, true)
+}
+
+// vectorDistance is the cosine distance between column and the query vector
+// $1, both cast to the given size so the planner can use that size's partial
+// index
+func vectorDistance(column string, dimensions int) string {
+	return fmt.Sprintf("%s::vector(%d) <=> $1::vector(%d)", column, dimensions, dimensions)
+}
+
+// scanSearchResults reads rows of the common result columns, including the
+// stored chunk metadata, followed by the similarity and per-ranking positions when hybrid is set
+func scanSearchResults(rows pgx.Rows, hybrid bool) ([]types.SearchResult, error) {
+	results := []types.SearchResult{}
+	for rows.Next() {
+		var result types.SearchResult
+		var ingestionJobID string
+		var createdAt *time.Time
+		var chunkIndex *int32
+		var similarity float64
+		var vectorRank, keywordRank int64
+		dest := []any{
+			&result.ID,
+			&result.Text,
+			&result.Citation,
+			&result.DatasourceID,
+			&result.Score,
+			&ingestionJobID,
+			&createdAt,
+			&result.ChunkMetadata,
+			&result.Topic,
+			&chunkIndex,
+		}
+		if hybrid {
+			dest = append(dest, &similarity, &vectorRank, &keywordRank)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+
+		if chunkIndex != nil {
+			index := int(*chunkIndex)
+			result.ChunkIndex = &index
+		}
+
+		result.Metadata = map[string]interface{}{}
+		if ingestionJobID != "" {
+			result.Metadata["ingestionJobId"] = ingestionJobID
+		}
+		if createdAt != nil {
+			result.Metadata["createdAt"] = createdAt.UTC()
+		}
+		if hybrid {
+			result.Metadata["similarity"] = similarity
+			if vectorRank > 0 {
+				result.Metadata["vectorRank"] = vectorRank
+			}
+			if keywordRank > 0 {
+				result.Metadata["keywordRank"] = keywordRank
+			}
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read search results: %w", err)
+	}
+	return results, nil
+}
+
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
@@ -0,0 +1,211 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/types"
+)
+
+// CreateWebhookDelivery stores an event that is due for delivery immediately
+func (c *PostgresClient) CreateWebhookDelivery(ctx context.Context, delivery types.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (id, job_id, event_type, url, payload, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	if _, err := c.pool.Exec(ctx, query,
+		delivery.ID,
+		delivery.JobID,
+		delivery.EventType,
+		delivery.URL,
+		delivery.Payload,
+		types.WebhookStatusPending,
+	); err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueWebhookDeliveries picks pending deliveries whose next attempt is due
+// and pushes their next attempt out by lease so other replicas skip them while
+// this one is sending
+func (c *PostgresClient) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]types.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1,
+			next_attempt_at = now() + make_interval(secs => $3),
+			updated_at = now()
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = $1 AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT $2
+		)
+		RETURNING id, job_id, event_type, url, payload, attempts
+	`
+	rows, err := c.pool.Query(ctx, query, types.WebhookStatusPending, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []types.WebhookDelivery
+	for rows.Next() {
+		var d types.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.JobID, &d.EventType, &d.URL, &d.Payload, &d.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempt stores the outcome of one attempt and moves the delivery
+// to status. nextAttemptAt is only used while the delivery stays pending.
+func (c *PostgresClient) RecordWebhookAttempt(ctx context.Context, deliveryID string, attempt types.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), $5)
+	`, deliveryID, attempt.Attempt, attempt.StatusCode, attempt.Error, attempt.DurationMs); err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			last_status_code = NULLIF($3, 0),
+			last_error = NULLIF($4, ''),
+			next_attempt_at = $5,
+			delivered_at = CASE WHEN $2 = $6 THEN now() ELSE delivered_at END,
+			updated_at = now()
+		WHERE id = $1
+	`, deliveryID, status, attempt.StatusCode, attempt.Error, nextAttemptAt, types.WebhookStatusDelivered); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ReplayWebhookDelivery makes a delivery due again with a fresh attempt budget
+func (c *PostgresClient) ReplayWebhookDelivery(ctx context.Context, deliveryID string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = 0, next_attempt_at = now(), updated_at = now()
+		WHERE id = $1
+	`
+	result, err := c.pool.Exec(ctx, query, deliveryID, types.WebhookStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetWebhookDelivery loads a delivery with its attempt history
+func (c *PostgresClient) GetWebhookDelivery(ctx context.Context, deliveryID string) (*types.WebhookDelivery, error) {
+	deliveries, err := c.listWebhookDeliveries(ctx, "id = $1", deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, ErrNotFound
+	}
+	return &deliveries[0], nil
+}
+
+// ListWebhookDeliveries returns every delivery recorded for a job, oldest first
+func (c *PostgresClient) ListWebhookDeliveries(ctx context.Context, jobID string) ([]types.WebhookDelivery, error) {
+	return c.listWebhookDeliveries(ctx, "job_id = $1", jobID)
+}
+
+func (c *PostgresClient) listWebhookDeliveries(ctx context.Context, where string, arg string) ([]types.WebhookDelivery, error) {
+	query := `
+		SELECT id, job_id, event_type, url, payload, status, attempts,
+			CASE WHEN status = 'pending' THEN next_attempt_at END,
+			COALESCE(last_status_code, 0), COALESCE(last_error, ''), created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE ` + where + `
+		ORDER BY created_at
+	`
+	rows, err := c.pool.Query(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []types.WebhookDelivery{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var d types.WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.JobID,
+			&d.EventType,
+			&d.URL,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.NextAttemptAt,
+			&d.LastStatusCode,
+			&d.LastError,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		d.History = []types.WebhookAttempt{}
+		index[d.ID] = len(deliveries)
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+	if len(deliveries) == 0 {
+		return deliveries, nil
+	}
+
+	ids := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.ID)
+	}
+
+	attemptRows, err := c.pool.Query(ctx, `
+		SELECT delivery_id, attempt, COALESCE(status_code, 0), COALESCE(error, ''), duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ANY($1)
+		ORDER BY attempted_at
+	`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook attempts: %w", err)
+	}
+	defer attemptRows.Close()
+
+	for attemptRows.Next() {
+		var deliveryID string
+		var a types.WebhookAttempt
+		if err := attemptRows.Scan(&deliveryID, &a.Attempt, &a.StatusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook attempt: %w", err)
+		}
+		if i, ok := index[deliveryID]; ok {
+			deliveries[i].History = append(deliveries[i].History, a)
+		}
+	}
+	if err := attemptRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook attempts: %w", err)
+	}
+
+	return deliveries, nil
+}
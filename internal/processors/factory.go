@@ -2,6 +2,7 @@ package processors
 
 import (
 	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
 
 	"strings"
 )
@@ -27,19 +28,51 @@ func (f *Factory) CreateQAProcessor(qa types.QAPair) types.Processor {
 	return NewQAProcessor(qa)
 }
 
-// CreateDocumentProcessorFromBytes creates a processor for document content from bytes
-func (f *Factory) CreateDocumentProcessorFromBytes(content []byte, filename, contentType string) types.Processor {
+// Document kinds, told apart by content type and file extension
+const (
+	documentPDF      = "pdf"
+	documentCSV      = "csv"
+	documentMarkdown = "markdown"
+	documentText     = "text"
+)
+
+// documentKind picks the processor for a document
+func documentKind(filename, contentType string) string {
 	filename = strings.ToLower(filename)
 
 	switch {
 	case strings.Contains(contentType, "pdf") || strings.HasSuffix(filename, ".pdf"):
-		return NewPDFProcessorFromBytes(content, filename, f.config)
+		return documentPDF
 	case strings.Contains(contentType, "csv") || strings.HasSuffix(filename, ".csv"):
-		return NewCSVProcessorFromBytes(content, filename)
+		return documentCSV
 	case strings.HasSuffix(filename, ".md") || strings.HasSuffix(filename, ".markdown"):
+		return documentMarkdown
+	default:
+		return documentText
+	}
+}
+
+// ChunkedBySize reports whether a document is split by the configured chunk
+// size and unit. CSV rows and markdown sections are kept whole instead.
+func ChunkedBySize(filename, contentType string) bool {
+	switch documentKind(filename, contentType) {
+	case documentCSV, documentMarkdown:
+		return false
+	}
+	return true
+}
+
+// CreateDocumentProcessorFromBytes creates a processor for document content from bytes
+func (f *Factory) CreateDocumentProcessorFromBytes(content []byte, filename, contentType string) types.Processor {
+	filename = strings.ToLower(filename)
+
+	switch documentKind(filename, contentType) {
+	case documentPDF:
+		return NewPDFProcessorFromBytes(content, filename, f.config)
+	case documentCSV:
+		return NewCSVProcessorFromBytes(content, filename)
+	case documentMarkdown:
 		return NewMarkdownProcessorFromBytes(content, filename)
-	case strings.Contains(contentType, "text") || strings.HasSuffix(filename, ".txt"):
-		return NewTextFileProcessorFromBytes(content, filename, f.config)
 	default:
 		return NewTextFileProcessorFromBytes(content, filename, f.config)
 	}
@@ -49,3 +82,19 @@ func (f *Factory) CreateTextProcessor(text, topic string) types.Processor {
 	return NewTextProcessor(text, topic, f.config)
 }
 
+// chunkTokenizer returns what the config's chunk sizes are measured with
+func chunkTokenizer(config *types.Config) utils.Tokenizer {
+	if config.ChunkUnit == types.ChunkUnitTokens {
+		return utils.DefaultTokenizer
+	}
+	return utils.Characters
+}
+
+// splitterLenFunc returns the Eino splitter length function for the config's
+// chunk unit; nil keeps the splitter's own
+func splitterLenFunc(config *types.Config) func(string) int {
+	if config.ChunkUnit == types.ChunkUnitTokens {
+		return utils.DefaultTokenizer.CountTokens
+	}
+	return nil
+}
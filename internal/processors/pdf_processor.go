@@ -91,7 +91,7 @@ func (p *PDFProcessor) Process(ctx context.Context, chatbotID, userID string) (*
 		zap.Int("contentLength", len(fullContent)))
 
 	// Chunk the content using the shared chunker
-	chunker := utils.NewChunker(p.Config.ChunkSize, p.Config.ChunkOverlap)
+	chunker := utils.NewTokenChunker(p.Config.ChunkSize, p.Config.ChunkOverlap, chunkTokenizer(p.Config))
 	textChunks := chunker.ChunkText(fullContent)
 
 	// Convert to ContentChunks
@@ -77,6 +77,7 @@ func (p *TextProcessor) Process(ctx context.Context, chatbotID, userID string) (
 		ChunkSize:   p.Config.ChunkSize,
 		OverlapSize: p.Config.ChunkOverlap,
 		Separators:  []string{"\n\n", "\n", ". ", "? ", "! ", " "},
+		LenFunc:     splitterLenFunc(p.Config),
 		KeepType:    recursive.KeepTypeNone,
 	})
 	if err != nil {
@@ -64,6 +64,7 @@ func (p *WebsiteProcessor) Process(ctx context.Context, chatbotID, userID string
 		ChunkSize:   p.Config.ChunkSize,
 		OverlapSize: p.Config.ChunkOverlap,
 		Separators:  []string{"\n\n", "\n", ". ", "? ", "! ", " "},
+		LenFunc:     splitterLenFunc(p.Config),
 		KeepType:    recursive.KeepTypeNone,
 	})
 	if err != nil {
@@ -0,0 +1,220 @@
+package reembed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+)
+
+// defaultBatchSize is the number of rows embedded and saved per step
+const defaultBatchSize = 100
+
+// maxCutoverAttempts bounds the catch-up passes made when rows keep arriving
+// between catching up and cutting over
+const maxCutoverAttempts = 5
+
+// Runner recomputes stored embeddings with new settings. Runs write to a
+// shadow table, so the chatbots keep serving their current vectors until
+// cutover swaps the new ones in.
+type Runner struct {
+	db        *loaders.PostgresClient
+	embedder  embedder.Embedder
+	cache     *embedder.Cache
+	batchSize int
+}
+
+// NewRunner creates a runner. textEmbedder is the configured embedder that
+// run settings are applied to; cache may be nil.
+func NewRunner(db *loaders.PostgresClient, textEmbedder embedder.Embedder, cache *embedder.Cache, batchSize int) *Runner {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Runner{db: db, embedder: textEmbedder, cache: cache, batchSize: batchSize}
+}
+
+// Start records a run re-embedding the chatbot's rows, or every row when
+// chatbotID is empty, with settings; Run does the embedding. The vector index
+// for the target dimensions is built first, so a size that cannot be stored
+// or indexed is refused before any row is embedded.
+func (r *Runner) Start(ctx context.Context, chatbotID string, settings embedder.Settings) (*types.ReembedRun, error) {
+	textEmbedder, err := r.embedder.WithSettings(settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding settings: %w", err)
+	}
+	if err := r.db.EnsureVectorIndex(ctx, textEmbedder.Dimensions()); err != nil {
+		return nil, err
+	}
+
+	run := types.ReembedRun{
+		ID:               uuid.New().String(),
+		ChatbotID:        chatbotID,
+		Model:            settings.Model,
+		TaskType:         settings.TaskType,
+		Dimensions:       settings.Dimensions,
+		ModelID:          textEmbedder.ModelID(),
+		TargetDimensions: textEmbedder.Dimensions(),
+	}
+	if err := r.db.CreateReembedRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return r.db.ResumeReembedRun(ctx, run.ID)
+}
+
+// Run embeds the rows of the run's scope that have no new vector yet, batch by
+// batch, until none are left and marks the run ready for cutover. Cancelling
+// ctx stops it between batches; a stopped or failed run continues with the
+// rows still missing when Run is called again.
+func (r *Runner) Run(ctx context.Context, runID string) (*types.ReembedRun, error) {
+	run, err := r.db.ResumeReembedRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	textEmbedder, err := r.embedder.WithSettings(embedder.Settings{
+		Model:      run.Model,
+		TaskType:   run.TaskType,
+		Dimensions: run.Dimensions,
+	})
+	if err != nil {
+		return nil, r.fail(run, fmt.Errorf("invalid embedding settings: %w", err))
+	}
+	if textEmbedder.ModelID() != run.ModelID || textEmbedder.Dimensions() != run.TargetDimensions {
+		return nil, r.fail(run, fmt.Errorf("configured embedder produces %s with %d dimensions, run targets %s with %d",
+			textEmbedder.ModelID(), textEmbedder.Dimensions(), run.ModelID, run.TargetDimensions))
+	}
+
+	utils.Zlog.Info("Re-embedding rows",
+		zap.String("runId", run.ID),
+		zap.String("chatbotId", run.ChatbotID),
+		zap.String("model", run.ModelID),
+		zap.Int("dimensions", run.TargetDimensions),
+		zap.Int("embedded", run.EmbeddedRows),
+		zap.Int("total", run.TotalRows))
+
+	start := time.Now()
+	var cacheStats embedder.CacheStats
+	for {
+		if err := ctx.Err(); err != nil {
+			return run, err
+		}
+
+		rows, err := r.db.NextReembedBatch(ctx, run, r.batchSize)
+		if err != nil {
+			return run, r.failUnlessCancelled(ctx, run, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		texts := make([]string, len(rows))
+		for i, row := range rows {
+			texts[i] = row.Text
+		}
+		vectors, stats, err := r.cache.EmbedBatch(ctx, textEmbedder, texts)
+		if err != nil {
+			return run, r.failUnlessCancelled(ctx, run, fmt.Errorf("failed to embed rows %d to %d: %w", rows[0].ID, rows[len(rows)-1].ID, err))
+		}
+		cacheStats.Hits += stats.Hits
+		cacheStats.Misses += stats.Misses
+
+		if err := r.db.SaveReembedBatch(ctx, run, rows, vectors); err != nil {
+			return run, r.failUnlessCancelled(ctx, run, err)
+		}
+
+		utils.Zlog.Info("Re-embedding progress",
+			zap.String("runId", run.ID),
+			zap.Int("embedded", run.EmbeddedRows),
+			zap.Int("total", run.TotalRows),
+			zap.Int64("lastEmbeddingId", run.LastEmbeddingID))
+	}
+
+	if err := r.db.SetReembedRunStatus(ctx, run.ID, types.ReembedStatusReady, ""); err != nil {
+		return run, err
+	}
+	run.Status = types.ReembedStatusReady
+
+	utils.Zlog.Info("Re-embedding run ready for cutover",
+		zap.String("runId", run.ID),
+		zap.Int("embedded", run.EmbeddedRows),
+		zap.Int("cacheHits", cacheStats.Hits),
+		zap.Duration("duration", time.Since(start)))
+	return run, nil
+}
+
+// Cutover catches the run up with rows written since it last ran, makes sure
+// the vector index for its dimensions still exists, and swaps its vectors in
+func (r *Runner) Cutover(ctx context.Context, runID string) (*types.ReembedRun, error) {
+	for attempt := 1; ; attempt++ {
+		run, err := r.Run(ctx, runID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := r.db.EnsureVectorIndex(ctx, run.TargetDimensions); err != nil {
+			return nil, err
+		}
+
+		run, err = r.db.CutoverReembedRun(ctx, runID)
+		if errors.Is(err, loaders.ErrReembedIncomplete) && attempt < maxCutoverAttempts {
+			utils.Zlog.Info("Rows arrived during cutover, catching up again",
+				zap.String("runId", runID),
+				zap.Int("attempt", attempt),
+				zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		utils.Zlog.Info("Re-embedding run cut over",
+			zap.String("runId", run.ID),
+			zap.String("chatbotId", run.ChatbotID),
+			zap.String("model", run.ModelID),
+			zap.Int("rows", run.EmbeddedRows))
+		return run, nil
+	}
+}
+
+// Rollback restores the vectors and chatbot settings the run replaced
+func (r *Runner) Rollback(ctx context.Context, runID string) (*loaders.ReembedRollback, error) {
+	result, err := r.db.RollbackReembedRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	utils.Zlog.Info("Re-embedding run rolled back",
+		zap.String("runId", runID),
+		zap.Int64("reverted", result.Reverted),
+		zap.Int64("stranded", result.Stranded))
+	return result, nil
+}
+
+// failUnlessCancelled marks the run failed unless ctx was cancelled, which
+// leaves it running so it can simply be resumed
+func (r *Runner) failUnlessCancelled(ctx context.Context, run *types.ReembedRun, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return r.fail(run, err)
+}
+
+func (r *Runner) fail(run *types.ReembedRun, err error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if statusErr := r.db.SetReembedRunStatus(ctx, run.ID, types.ReembedStatusFailed, err.Error()); statusErr != nil {
+		utils.Zlog.Error("Failed to mark re-embedding run failed",
+			zap.String("runId", run.ID),
+			zap.Error(statusErr))
+	}
+	run.Status = types.ReembedStatusFailed
+	run.ErrorMessage = err.Error()
+	return err
+}
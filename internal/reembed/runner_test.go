@@ -0,0 +1,183 @@
+package reembed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"github.com/Conversly/db-ingestor/internal/embedder"
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+)
+
+// TestCutoverAndRollback walks a run through embedding, cutover and rollback
+// for a chatbot of its own. It needs a migrated database in TEST_DATABASE_URL
+// and removes the chatbot's rows when it ends.
+//
+//	TEST_DATABASE_URL=postgres://... go test ./internal/reembed
+func TestCutoverAndRollback(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	utils.Zlog = zap.NewNop()
+	ctx := context.Background()
+
+	db, err := loaders.NewPostgresClient(dsn, 1, 0)
+	if err != nil {
+		t.Fatalf("NewPostgresClient() error = %v", err)
+	}
+	defer db.Close()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer conn.Close(ctx)
+
+	chatbotID := "test-reembed-" + uuid.New().String()
+	t.Cleanup(func() { cleanup(t, conn, chatbotID) })
+
+	oldEmbedder, err := embedder.NewHashEmbedder(embedder.Settings{Dimensions: 32})
+	if err != nil {
+		t.Fatalf("NewHashEmbedder() error = %v", err)
+	}
+	target := embedder.Settings{Model: "hash-v2", Dimensions: 16}
+	newEmbedder, err := oldEmbedder.WithSettings(target)
+	if err != nil {
+		t.Fatalf("WithSettings() error = %v", err)
+	}
+
+	insert := func(textEmbedder embedder.Embedder, texts ...string) error {
+		t.Helper()
+		vectors, err := textEmbedder.EmbedBatch(ctx, texts)
+		if err != nil {
+			t.Fatalf("EmbedBatch() error = %v", err)
+		}
+		chunks := make([]loaders.EmbeddingData, len(texts))
+		for i, text := range texts {
+			chunks[i] = loaders.EmbeddingData{Text: text, Vector: vectors[i], Model: textEmbedder.ModelID()}
+		}
+		_, err = db.BatchInsertEmbeddings(ctx, "test-user", chatbotID, chunks)
+		return err
+	}
+	// models counts the chatbot's rows by model and dimensions
+	models := func() map[string]int {
+		t.Helper()
+		rows, err := conn.Query(ctx, `
+			SELECT embedding_model || '/' || embedding_dimensions || '/' || vector_dims(vector), count(*)
+			FROM embeddings WHERE chatbot_id = $1 GROUP BY 1
+		`, chatbotID)
+		if err != nil {
+			t.Fatalf("failed to count rows: %v", err)
+		}
+		counts := make(map[string]int)
+		for rows.Next() {
+			var key string
+			var n int
+			if err := rows.Scan(&key, &n); err != nil {
+				t.Fatalf("failed to count rows: %v", err)
+			}
+			counts[key] = n
+		}
+		return counts
+	}
+	oldKey := fmt.Sprintf("%s/32/32", oldEmbedder.ModelID())
+	newKey := fmt.Sprintf("%s/16/16", newEmbedder.ModelID())
+
+	if err := insert(oldEmbedder, "refund policy", "shipping times", "annual plans"); err != nil {
+		t.Fatalf("insert error = %v", err)
+	}
+
+	runner := NewRunner(db, oldEmbedder, nil, 2)
+	run, err := runner.Start(ctx, chatbotID, target)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if run.TotalRows != 3 {
+		t.Errorf("TotalRows = %d, want 3", run.TotalRows)
+	}
+
+	run, err = runner.Run(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if run.Status != types.ReembedStatusReady || run.EmbeddedRows != 3 {
+		t.Errorf("run is %s with %d rows, want %s with 3", run.Status, run.EmbeddedRows, types.ReembedStatusReady)
+	}
+	if got := models(); got[oldKey] != 3 || len(got) != 1 {
+		t.Errorf("before cutover rows = %v, want 3 on %s", got, oldKey)
+	}
+
+	// Rows written before cutover are caught up by it
+	if err := insert(oldEmbedder, "late arrival"); err != nil {
+		t.Fatalf("insert before cutover error = %v", err)
+	}
+
+	run, err = runner.Cutover(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("Cutover() error = %v", err)
+	}
+	if run.Status != types.ReembedStatusActive || run.CutoverAt == nil {
+		t.Errorf("run is %s, cut over at %v, want %s", run.Status, run.CutoverAt, types.ReembedStatusActive)
+	}
+	if got := models(); got[newKey] != 4 || len(got) != 1 {
+		t.Errorf("after cutover rows = %v, want 4 on %s", got, newKey)
+	}
+	settings, err := db.GetChatbotEmbeddingSettings(ctx, chatbotID)
+	if err != nil || settings == nil || settings.Model != target.Model || settings.Dimensions != target.Dimensions {
+		t.Errorf("chatbot settings = %+v, error %v, want %+v", settings, err, target)
+	}
+
+	if err := insert(oldEmbedder, "stale worker"); !errors.Is(err, loaders.ErrEmbeddingSettingsChanged) {
+		t.Errorf("insert with the old settings error = %v, want %v", err, loaders.ErrEmbeddingSettingsChanged)
+	}
+	if err := insert(newEmbedder, "after cutover"); err != nil {
+		t.Fatalf("insert with the new settings error = %v", err)
+	}
+	if _, err := runner.Cutover(ctx, run.ID); !errors.Is(err, loaders.ErrReembedState) {
+		t.Errorf("second Cutover() error = %v, want %v", err, loaders.ErrReembedState)
+	}
+
+	result, err := runner.Rollback(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if result.Reverted != 4 || result.Stranded != 1 {
+		t.Errorf("Rollback() = %+v, want 4 reverted and 1 stranded", result)
+	}
+	if got := models(); got[oldKey] != 4 || got[newKey] != 1 || len(got) != 2 {
+		t.Errorf("after rollback rows = %v, want 4 on %s and 1 on %s", got, oldKey, newKey)
+	}
+	if settings, err := db.GetChatbotEmbeddingSettings(ctx, chatbotID); err != nil || settings != nil {
+		t.Errorf("chatbot settings = %+v, error %v, want none", settings, err)
+	}
+	if err := insert(oldEmbedder, "back on the old model"); err != nil {
+		t.Errorf("insert after rollback error = %v", err)
+	}
+
+	if _, err := runner.Rollback(ctx, run.ID); !errors.Is(err, loaders.ErrReembedState) {
+		t.Errorf("second Rollback() error = %v, want %v", err, loaders.ErrReembedState)
+	}
+}
+
+func cleanup(t *testing.T, conn *pgx.Conn, chatbotID string) {
+	ctx := context.Background()
+	for _, query := range []string{
+		`DELETE FROM reembed_vectors WHERE run_id IN (SELECT id FROM reembed_runs WHERE chatbot_id = $1)`,
+		`DELETE FROM reembed_run_chatbots WHERE chatbot_id = $1`,
+		`DELETE FROM reembed_runs WHERE chatbot_id = $1`,
+		`DELETE FROM chatbot_embedding_settings WHERE chatbot_id = $1`,
+		`DELETE FROM embeddings WHERE chatbot_id = $1`,
+	} {
+		if _, err := conn.Exec(ctx, query, chatbotID); err != nil {
+			t.Errorf("cleanup error = %v", err)
+		}
+	}
+}
@@ -4,21 +4,34 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/Conversly/db-ingestor/internal/api/chatbots"
+	"github.com/Conversly/db-ingestor/internal/api/datasources"
 	"github.com/Conversly/db-ingestor/internal/api/ingestion"
+	"github.com/Conversly/db-ingestor/internal/api/search"
+	webhooksapi "github.com/Conversly/db-ingestor/internal/api/webhooks"
 	"github.com/Conversly/db-ingestor/internal/config"
 	"github.com/Conversly/db-ingestor/internal/controllers"
+	"github.com/Conversly/db-ingestor/internal/embedder"
 	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupAPIRoutes(router *gin.Engine, db *loaders.PostgresClient, cfg *config.Config) {
+func SetupAPIRoutes(router *gin.Engine, db *loaders.PostgresClient, cfg *config.Config, ingestionService *ingestion.Service, dispatcher *webhooks.Dispatcher, textEmbedder embedder.Embedder) {
 	v1 := router.Group("/api/v1")
 	{
 		systemController := controllers.NewSystemController(cfg)
 		v1.GET("/status", systemController.Status)
 		v1.GET("/info", systemController.Info)
 
-		ingestion.RegisterRoutes(v1, db, cfg)
+		// Worker-only processes expose health and status but accept no jobs
+		if cfg.RunsAPI() {
+			ingestion.RegisterRoutes(v1, ingestionService)
+			webhooksapi.RegisterRoutes(v1, db, dispatcher)
+			datasources.RegisterRoutes(v1, db)
+			search.RegisterRoutes(v1, db, textEmbedder)
+			chatbots.RegisterRoutes(v1, db, textEmbedder)
+		}
 	}
 }
 
@@ -1,14 +1,17 @@
 package routes
 
 import (
+	"github.com/Conversly/db-ingestor/internal/api/ingestion"
 	"github.com/Conversly/db-ingestor/internal/config"
+	"github.com/Conversly/db-ingestor/internal/embedder"
 	"github.com/Conversly/db-ingestor/internal/loaders"
 	"github.com/Conversly/db-ingestor/internal/middleware"
+	"github.com/Conversly/db-ingestor/internal/webhooks"
 	"github.com/gin-gonic/gin"
 )
 
 // SetupRoutes configures all application routes
-func SetupRoutes(router *gin.Engine, db *loaders.PostgresClient, cfg *config.Config) {
+func SetupRoutes(router *gin.Engine, db *loaders.PostgresClient, cfg *config.Config, ingestionService *ingestion.Service, dispatcher *webhooks.Dispatcher, textEmbedder embedder.Embedder) {
 	// Apply global middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
@@ -17,7 +20,7 @@ func SetupRoutes(router *gin.Engine, db *loaders.PostgresClient, cfg *config.Con
 
 	// Setup route groups
 	SetupHealthRoutes(router, db)
-	SetupAPIRoutes(router, db, cfg)
+	SetupAPIRoutes(router, db, cfg, ingestionService, dispatcher, textEmbedder)
 	SetupRootRoutes(router, cfg)
 	Setup404Handler(router)
 }
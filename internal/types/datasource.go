@@ -22,9 +22,20 @@ type ProcessStatus string
 const (
 	StatusPending    ProcessStatus = "pending"
 	StatusProcessing ProcessStatus = "processing"
+	StatusEmbedding  ProcessStatus = "embedding"
 	StatusCompleted  ProcessStatus = "completed"
 	StatusFailed     ProcessStatus = "failed"
 	StatusPartial    ProcessStatus = "partial"
+	StatusCancelled  ProcessStatus = "cancelled"
+)
+
+// Per-source statuses reported in SourceResult.Status
+const (
+	SourceStatusSuccess   = "success"
+	SourceStatusFailed    = "failed"
+	SourceStatusEmbedding = "embedding"
+	SourceStatusCompleted = "completed"
+	SourceStatusCancelled = "cancelled"
 )
 
 // ====== DATA STRUCTURES ======
@@ -50,14 +61,56 @@ type DocumentMetadata struct {
 	ContentDisposition string `json:"contentDisposition" validate:"required"`
 }
 
+// UploadedDocument is a file received by POST /process/upload and held in the
+// upload spool until a worker processes it
+type UploadedDocument struct {
+	DatasourceID string `json:"datasourceId" validate:"required"`
+	Filename     string `json:"filename" validate:"required"`
+	ContentType  string `json:"contentType,omitempty"`
+	SpoolFile    string `json:"spoolFile" validate:"required"`
+	Size         int64  `json:"size"`
+}
+
+// UploadFileMetadata describes one file part of a multipart upload, matched to
+// the file parts by position
+type UploadFileMetadata struct {
+	DatasourceID string `json:"datasourceId" validate:"required"`
+	ContentType  string `json:"contentType,omitempty"`
+}
+
+// UploadRequest is the metadata part of POST /process/upload
+type UploadRequest struct {
+	ProcessRequest
+	Files []UploadFileMetadata `json:"files" validate:"required,min=1,max=100,dive"`
+}
+
 type TextContent struct {
 	DatasourceID string `json:"datasourceId" validate:"required"`
 	Content      string `json:"content" validate:"required"`
 }
 
+// Embedding write modes selected with ProcessingOptions.Mode
+const (
+	// WriteModeAppend adds the new embeddings next to existing ones
+	WriteModeAppend = "append"
+	// WriteModeReplace removes a datasource's previous embeddings once the new
+	// set is fully persisted
+	WriteModeReplace = "replace"
+)
+
+// Units chunk sizes are measured in, selected with ProcessingOptions.ChunkUnit
+const (
+	// ChunkUnitCharacters measures chunks in characters
+	ChunkUnitCharacters = "characters"
+	// ChunkUnitTokens measures chunks in estimated embedding model tokens
+	ChunkUnitTokens = "tokens"
+)
+
 type ProcessingOptions struct {
-	ChunkSize    int `json:"chunkSize,omitempty" validate:"omitempty,min=0"`
-	ChunkOverlap int `json:"chunkOverlap,omitempty" validate:"omitempty,min=0"`
+	ChunkSize    int    `json:"chunkSize,omitempty" validate:"omitempty,min=0"`
+	ChunkOverlap int    `json:"chunkOverlap,omitempty" validate:"omitempty,min=0"`
+	ChunkUnit    string `json:"chunkUnit,omitempty" validate:"omitempty,oneof=characters tokens"`
+	Mode         string `json:"mode,omitempty" validate:"omitempty,oneof=append replace"`
 }
 
 // request structure for processing ingestion
@@ -69,18 +122,23 @@ type ProcessRequest struct {
 	QandAData   []QAPair           `json:"qandaData,omitempty" validate:"omitempty,dive"`
 	Documents   []DocumentMetadata `json:"documents,omitempty" validate:"omitempty,dive"`
 	TextContent []TextContent      `json:"textContent,omitempty" validate:"omitempty,dive"`
+	Uploads     []UploadedDocument `json:"uploads,omitempty" validate:"omitempty,dive"` // set by POST /process/upload only
 	Options     *ProcessingOptions `json:"options,omitempty"`
+	CallbackURL string             `json:"callbackUrl,omitempty" validate:"omitempty,url"`
+	// IdempotencyKey may also be sent as the Idempotency-Key header
+	IdempotencyKey string `json:"idempotencyKey,omitempty" validate:"omitempty,max=255"`
 }
 
 type SourceResult struct {
-	DatasourceID string     `json:"datasourceId,omitempty"`
-	SourceType   SourceType `json:"sourceType"`
-	Source       string     `json:"source"`
-	Status       string     `json:"status"`
-	Message      string     `json:"message,omitempty"`
-	Error        string     `json:"error,omitempty"`
-	ChunkCount   int        `json:"chunkCount"`
-	ProcessedAt  time.Time  `json:"processedAt"`
+	DatasourceID   string     `json:"datasourceId,omitempty"`
+	SourceType     SourceType `json:"sourceType"`
+	Source         string     `json:"source"`
+	Status         string     `json:"status"`
+	Message        string     `json:"message,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	ChunkCount     int        `json:"chunkCount"`
+	EmbeddedChunks int        `json:"embeddedChunks"`
+	ProcessedAt    time.Time  `json:"processedAt"`
 }
 
 type ProcessResponse struct {
@@ -95,6 +153,21 @@ type ProcessResponse struct {
 	Timestamp        time.Time      `json:"timestamp"`
 }
 
+// PreviewResponse is the outcome of chunking a request without embedding or
+// persisting anything
+type PreviewResponse struct {
+	Status           ProcessStatus     `json:"status"`
+	Options          ProcessingOptions `json:"options"` // effective chunking options
+	TotalSources     int               `json:"totalSources"`
+	ProcessedSources int               `json:"processedSources"`
+	FailedSources    int               `json:"failedSources"`
+	TotalChunks      int               `json:"totalChunks"`
+	Results          []SourceResult    `json:"results"`
+	Chunks           []ContentChunk    `json:"chunks"`
+	Duration         string            `json:"duration"`
+	Timestamp        time.Time         `json:"timestamp"`
+}
+
 type ErrorResponse struct {
 	Error     string                 `json:"error"`
 	Message   string                 `json:"message"`
@@ -113,11 +186,19 @@ type IngestionRecord struct {
 	ProcessedSources int                    `db:"processed_sources" json:"processedSources"`
 	FailedSources    int                    `db:"failed_sources" json:"failedSources"`
 	TotalChunks      int                    `db:"total_chunks" json:"totalChunks"`
+	EmbeddedChunks   int                    `db:"embedded_chunks" json:"embeddedChunks"`
+	PendingSources   int                    `db:"pending_sources" json:"pendingSources"`
+	DatasourceIDs    []string               `db:"datasource_ids" json:"datasourceIds,omitempty"`
+	CallbackURL      string                 `db:"callback_url" json:"callbackUrl,omitempty"`
+	IdempotencyKey   string                 `db:"idempotency_key" json:"idempotencyKey,omitempty"`
+	RequestHash      string                 `db:"request_hash" json:"-"`
+	Response         []byte                 `db:"response" json:"-"` // ProcessResponse returned for duplicate submissions
 	Metadata         map[string]interface{} `db:"metadata" json:"metadata,omitempty"`
 	ErrorMessage     string                 `db:"error_message" json:"errorMessage,omitempty"`
 	CreatedAt        time.Time              `db:"created_at" json:"createdAt"`
 	UpdatedAt        time.Time              `db:"updated_at" json:"updatedAt"`
 	CompletedAt      *time.Time             `db:"completed_at" json:"completedAt,omitempty"`
+	Results          []SourceResult         `db:"-" json:"results"`
 }
 
 type FileInfo struct {
@@ -143,3 +224,29 @@ func DetermineSourceTypeFromContentType(contentType string) SourceType {
 		return SourceTypeText
 	}
 }
+
+// Ingestion job progress event types
+const (
+	JobEventSourceStarted   = "source-started"
+	JobEventSourceProcessed = "source-processed"
+	JobEventChunksEmbedded  = "chunks-embedded"
+	JobEventPersisted       = "persisted"
+	JobEventTerminal        = "terminal"
+)
+
+// JobEvent is one step of ingestion progress, streamed to clients over SSE
+type JobEvent struct {
+	ID           int64                  `db:"id" json:"id"`
+	JobID        string                 `db:"job_id" json:"jobId"`
+	Type         string                 `db:"type" json:"type"`
+	DatasourceID string                 `db:"datasource_id" json:"datasourceId,omitempty"`
+	Data         map[string]interface{} `db:"data" json:"data,omitempty"`
+	CreatedAt    time.Time              `db:"created_at" json:"createdAt"`
+}
+
+// DeleteDatasourceResponse reports the embeddings purged for a datasource
+type DeleteDatasourceResponse struct {
+	DatasourceID      string    `json:"datasourceId"`
+	DeletedEmbeddings int64     `json:"deletedEmbeddings"`
+	Timestamp         time.Time `json:"timestamp"`
+}
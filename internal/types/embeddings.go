@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// ChatbotEmbeddingSettings overrides the global embedding model, task type or
+// dimensions for one chatbot. Empty fields fall back to the global settings.
+type ChatbotEmbeddingSettings struct {
+	ChatbotID  string    `json:"chatbotId"`
+	Model      string    `json:"model,omitempty"`
+	TaskType   string    `json:"taskType,omitempty"`
+	Dimensions int       `json:"dimensions,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// UpdateEmbeddingSettingsRequest is the body of PUT /chatbots/:chatbotId/embedding-settings
+type UpdateEmbeddingSettingsRequest struct {
+	Model      string `json:"model,omitempty"`
+	TaskType   string `json:"taskType,omitempty"`
+	Dimensions int    `json:"dimensions,omitempty" validate:"omitempty,min=1,max=2000"`
+}
+
+// EmbeddingSettingsResponse is the effective embedding configuration of a
+// chatbot: the model new chunks are tagged with and searches are scoped to
+type EmbeddingSettingsResponse struct {
+	ChatbotID  string `json:"chatbotId"`
+	ModelID    string `json:"modelId"`
+	TaskType   string `json:"taskType,omitempty"`
+	Dimensions int    `json:"dimensions"`
+	// Override is the chatbot's stored settings, nil when the global ones apply
+	Override  *ChatbotEmbeddingSettings `json:"override,omitempty"`
+	Timestamp time.Time                 `json:"timestamp"`
+}
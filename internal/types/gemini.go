@@ -26,3 +26,14 @@ type EmbeddingResponse struct {
 type Embedding struct {
 	Values []float32 `json:"values"`
 }
+
+// BatchEmbeddingRequest is the body of batchEmbedContents; every request must
+// name the model as "models/<model>"
+type BatchEmbeddingRequest struct {
+	Requests []EmbeddingRequest `json:"requests"`
+}
+
+// BatchEmbeddingResponse holds one embedding per request, in request order
+type BatchEmbeddingResponse struct {
+	Embeddings []Embedding `json:"embeddings"`
+}
@@ -0,0 +1,22 @@
+package types
+
+// OpenAIEmbeddingRequest is the body of POST /embeddings on OpenAI-compatible
+// servers (OpenAI, Ollama, llama.cpp, vLLM, ...)
+type OpenAIEmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
+}
+
+// OpenAIEmbeddingResponse holds one embedding per input, tagged with its position
+type OpenAIEmbeddingResponse struct {
+	Data  []OpenAIEmbedding `json:"data"`
+	Model string            `json:"model"`
+}
+
+// OpenAIEmbedding is one embedding of an OpenAIEmbeddingResponse
+type OpenAIEmbedding struct {
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
@@ -28,10 +28,12 @@ type ContentChunk struct {
 	ChunkIndex   int                    `json:"chunkIndex"`
 }
 
-// Config holds configuration for processors
+// Config holds configuration for processors. ChunkSize and ChunkOverlap are
+// measured in ChunkUnit, characters when empty.
 type Config struct {
 	ChunkSize    int
 	ChunkOverlap int
+	ChunkUnit    string
 }
 
 // DefaultConfig returns default configuration
@@ -39,5 +41,15 @@ func DefaultConfig() *Config {
 	return &Config{
 		ChunkSize:    1000,
 		ChunkOverlap: 200,
+		ChunkUnit:    ChunkUnitCharacters,
+	}
+}
+
+// DefaultTokenConfig returns the default configuration for chunks measured in tokens
+func DefaultTokenConfig() *Config {
+	return &Config{
+		ChunkSize:    512,
+		ChunkOverlap: 64,
+		ChunkUnit:    ChunkUnitTokens,
 	}
 }
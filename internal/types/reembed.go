@@ -0,0 +1,42 @@
+package types
+
+import "time"
+
+// ReembedStatus is the state of a re-embedding run
+type ReembedStatus string
+
+const (
+	// ReembedStatusRunning runs are embedding rows into the shadow table
+	ReembedStatusRunning ReembedStatus = "running"
+	// ReembedStatusReady runs have embedded every row and await cutover
+	ReembedStatusReady ReembedStatus = "ready"
+	// ReembedStatusActive runs have swapped their vectors into embeddings
+	ReembedStatusActive ReembedStatus = "active"
+	// ReembedStatusRolledBack runs were cut over and then reverted
+	ReembedStatusRolledBack ReembedStatus = "rolled_back"
+	// ReembedStatusFailed runs stopped on an error and can be resumed
+	ReembedStatusFailed ReembedStatus = "failed"
+)
+
+// ReembedRun recomputes the embeddings of a chatbot, or of every chatbot when
+// ChatbotID is empty, with new settings. Model, TaskType and Dimensions are
+// the overrides chatbots get at cutover; ModelID and TargetDimensions are
+// what the resulting rows are tagged with.
+type ReembedRun struct {
+	ID               string        `json:"id"`
+	ChatbotID        string        `json:"chatbotId,omitempty"`
+	Model            string        `json:"model,omitempty"`
+	TaskType         string        `json:"taskType,omitempty"`
+	Dimensions       int           `json:"dimensions,omitempty"`
+	ModelID          string        `json:"modelId"`
+	TargetDimensions int           `json:"targetDimensions"`
+	Status           ReembedStatus `json:"status"`
+	TotalRows        int           `json:"totalRows"`
+	EmbeddedRows     int           `json:"embeddedRows"`
+	LastEmbeddingID  int64         `json:"lastEmbeddingId"` // last row saved, for progress reports
+	ErrorMessage     string        `json:"errorMessage,omitempty"`
+	CreatedAt        time.Time     `json:"createdAt"`
+	UpdatedAt        time.Time     `json:"updatedAt"`
+	CutoverAt        *time.Time    `json:"cutoverAt,omitempty"`
+	RolledBackAt     *time.Time    `json:"rolledBackAt,omitempty"`
+}
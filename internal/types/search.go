@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+// Search modes
+const (
+	// SearchModeVector ranks chunks by cosine similarity only
+	SearchModeVector = "vector"
+	// SearchModeHybrid fuses vector and full-text rankings
+	SearchModeHybrid = "hybrid"
+)
+
+// SearchRequest is the body of POST /chatbots/:chatbotId/search
+type SearchRequest struct {
+	Query         string   `json:"query" validate:"required"`
+	TopK          int      `json:"topK,omitempty" validate:"omitempty,min=1,max=100"`
+	DatasourceIDs []string `json:"datasourceIds,omitempty" validate:"omitempty,dive,required"`
+	// MetadataFilter keeps only chunks whose metadata contains these key/value
+	// pairs, e.g. {"h2": "Installation"} or {"row_number": 12}
+	MetadataFilter map[string]interface{} `json:"metadataFilter,omitempty"`
+	Mode           string                 `json:"mode,omitempty" validate:"omitempty,oneof=vector hybrid"`
+	// Hybrid mode weights for each ranking, both default to 1. A weight of 0
+	// drops that ranking from the fused score.
+	VectorWeight  *float64 `json:"vectorWeight,omitempty" validate:"omitempty,min=0"`
+	KeywordWeight *float64 `json:"keywordWeight,omitempty" validate:"omitempty,min=0"`
+}
+
+// SearchResult is one stored chunk ranked by similarity to the query.
+// ChunkMetadata is the metadata its processor attached to the chunk.
+type SearchResult struct {
+	ID            string                 `json:"id"`
+	Text          string                 `json:"text"`
+	Citation      string                 `json:"citation,omitempty"`
+	DatasourceID  string                 `json:"datasourceId,omitempty"`
+	Score         float64                `json:"score"` // cosine similarity, or the fused rank score in hybrid mode
+	Topic         string                 `json:"topic,omitempty"`
+	ChunkIndex    *int                   `json:"chunkIndex,omitempty"`
+	ChunkMetadata map[string]interface{} `json:"chunkMetadata,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// SearchResponse lists the results of a search. ModelID and Dimensions
+// identify the embedding generation searched; chunks embedded with other
+// settings are not considered.
+type SearchResponse struct {
+	ChatbotID  string         `json:"chatbotId"`
+	Query      string         `json:"query"`
+	TopK       int            `json:"topK"`
+	Mode       string         `json:"mode"`
+	ModelID    string         `json:"modelId"`
+	Dimensions int            `json:"dimensions"`
+	Results    []SearchResult `json:"results"`
+	TookMs     int64          `json:"tookMs"`
+	Timestamp  time.Time      `json:"timestamp"`
+}
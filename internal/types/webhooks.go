@@ -0,0 +1,57 @@
+package types
+
+import "time"
+
+// Webhook event types sent to ProcessRequest.CallbackURL
+const (
+	WebhookEventDatasourceCompleted = "datasource.completed"
+	WebhookEventDatasourceFailed    = "datasource.failed"
+	WebhookEventJobFinished         = "job.finished"
+)
+
+// Webhook delivery states
+const (
+	WebhookStatusPending   = "pending"
+	WebhookStatusDelivered = "delivered"
+	WebhookStatusFailed    = "failed"
+)
+
+// WebhookEvent is the signed JSON body posted to a callback URL
+type WebhookEvent struct {
+	ID           string           `json:"id"`
+	Type         string           `json:"type"`
+	JobID        string           `json:"jobId"`
+	UserID       string           `json:"userId"`
+	ChatbotID    string           `json:"chatbotId"`
+	DatasourceID string           `json:"datasourceId,omitempty"`
+	Status       string           `json:"status"`
+	Source       *SourceResult    `json:"source,omitempty"`
+	Job          *IngestionRecord `json:"job,omitempty"`
+	OccurredAt   time.Time        `json:"occurredAt"`
+}
+
+// WebhookDelivery tracks delivery of one event to one callback URL
+type WebhookDelivery struct {
+	ID             string           `json:"id"`
+	JobID          string           `json:"jobId"`
+	EventType      string           `json:"eventType"`
+	URL            string           `json:"url"`
+	Payload        []byte           `json:"-"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  *time.Time       `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int              `json:"lastStatusCode,omitempty"`
+	LastError      string           `json:"lastError,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	DeliveredAt    *time.Time       `json:"deliveredAt,omitempty"`
+	History        []WebhookAttempt `json:"history"`
+}
+
+// WebhookAttempt is a single HTTP attempt of a delivery
+type WebhookAttempt struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
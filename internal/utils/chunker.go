@@ -1,19 +1,26 @@
 package utils
 
 import (
+	"sort"
 	"strings"
-	"unicode/utf8"
 )
 
-// Chunker splits text into overlapping chunks
+// Chunker splits text into overlapping chunks. Sizes are measured with
+// Tokenizer, in characters unless set otherwise.
 type Chunker struct {
 	ChunkSize    int
 	ChunkOverlap int
 	Separators   []string
+	Tokenizer    Tokenizer
 }
 
-// NewChunker creates a new Chunker with specified chunk size and overlap
+// NewChunker creates a new Chunker with specified chunk size and overlap in characters
 func NewChunker(chunkSize, chunkOverlap int) *Chunker {
+	return NewTokenChunker(chunkSize, chunkOverlap, Characters)
+}
+
+// NewTokenChunker creates a Chunker whose size and overlap are measured with tokenizer
+func NewTokenChunker(chunkSize, chunkOverlap int, tokenizer Tokenizer) *Chunker {
 	if chunkSize <= 0 {
 		chunkSize = 1000
 	}
@@ -27,9 +34,18 @@ func NewChunker(chunkSize, chunkOverlap int) *Chunker {
 		ChunkSize:    chunkSize,
 		ChunkOverlap: chunkOverlap,
 		Separators:   []string{"\n\n", "\n", ". ", "? ", "! ", "; ", ", ", " "},
+		Tokenizer:    tokenizer,
 	}
 }
 
+// length measures text in the chunker's unit
+func (c *Chunker) length(text string) int {
+	if c.Tokenizer == nil {
+		return Characters.CountTokens(text)
+	}
+	return c.Tokenizer.CountTokens(text)
+}
+
 // ChunkText splits text into chunks with overlap
 func (c *Chunker) ChunkText(text string) []string {
 	if text == "" {
@@ -37,9 +53,7 @@ func (c *Chunker) ChunkText(text string) []string {
 	}
 
 	text = strings.TrimSpace(text)
-	textLen := utf8.RuneCountInString(text)
-
-	if textLen <= c.ChunkSize {
+	if c.length(text) <= c.ChunkSize {
 		return []string{text}
 	}
 
@@ -47,7 +61,7 @@ func (c *Chunker) ChunkText(text string) []string {
 }
 
 func (c *Chunker) recursiveSplit(text string, separators []string) []string {
-	if utf8.RuneCountInString(text) <= c.ChunkSize {
+	if c.length(text) <= c.ChunkSize {
 		if strings.TrimSpace(text) != "" {
 			return []string{strings.TrimSpace(text)}
 		}
@@ -87,7 +101,7 @@ func (c *Chunker) recursiveSplit(text string, separators []string) []string {
 			testContent = part
 		}
 
-		if utf8.RuneCountInString(testContent) <= c.ChunkSize {
+		if c.length(testContent) <= c.ChunkSize {
 			if currentChunk.Len() > 0 {
 				currentChunk.WriteString(bestSep)
 			}
@@ -102,7 +116,7 @@ func (c *Chunker) recursiveSplit(text string, separators []string) []string {
 			}
 
 			// Handle part that might be too large
-			if utf8.RuneCountInString(part) > c.ChunkSize {
+			if c.length(part) > c.ChunkSize {
 				// Try with next separator level
 				nextSeps := separators
 				for j, sep := range separators {
@@ -143,32 +157,56 @@ func (c *Chunker) recursiveSplit(text string, separators []string) []string {
 	return chunks
 }
 
+// splitBySize cuts text without separators into the longest runs of runes
+// that fit, each starting within the overlap of the previous one
 func (c *Chunker) splitBySize(text string) []string {
 	runes := []rune(text)
 	var chunks []string
 
 	for i := 0; i < len(runes); {
-		end := i + c.ChunkSize
-		if end > len(runes) {
-			end = len(runes)
+		// Longest prefix from i that fits, at least one rune. The window
+		// doubles until it overflows so the search stays near the chunk size.
+		window := min(c.ChunkSize, len(runes)-i)
+		for i+window < len(runes) && c.length(string(runes[i:i+window])) <= c.ChunkSize {
+			window = min(2*window, len(runes)-i)
+		}
+		end := i + sort.Search(window, func(n int) bool {
+			return c.length(string(runes[i:i+n+1])) > c.ChunkSize
+		})
+		if end == i {
+			end = i + 1
 		}
 
 		chunk := strings.TrimSpace(string(runes[i:end]))
 		if chunk != "" {
 			chunks = append(chunks, chunk)
 		}
+		if end == len(runes) {
+			break
+		}
 
-		// Move forward by (chunkSize - overlap)
-		step := c.ChunkSize - c.ChunkOverlap
-		if step <= 0 {
-			step = c.ChunkSize
+		// Move forward to the start of the overlap, always making progress
+		next := c.overlapStart(runes[i:end]) + i
+		if next <= i {
+			next = end
 		}
-		i += step
+		i = next
 	}
 
 	return chunks
 }
 
+// overlapStart returns the index of the longest suffix of runes that fits in
+// the overlap, or len(runes) when there is no overlap
+func (c *Chunker) overlapStart(runes []rune) int {
+	if c.ChunkOverlap <= 0 {
+		return len(runes)
+	}
+	return sort.Search(len(runes), func(j int) bool {
+		return c.length(string(runes[j:])) <= c.ChunkOverlap
+	})
+}
+
 func (c *Chunker) applyOverlap(chunks []string) []string {
 	if len(chunks) <= 1 {
 		return chunks
@@ -182,15 +220,10 @@ func (c *Chunker) applyOverlap(chunks []string) []string {
 		prevRunes := []rune(prevChunk)
 
 		// Get overlap from end of previous chunk
-		overlapStart := len(prevRunes) - c.ChunkOverlap
-		if overlapStart < 0 {
-			overlapStart = 0
-		}
-
-		overlap := string(prevRunes[overlapStart:])
+		overlap := string(prevRunes[c.overlapStart(prevRunes):])
 
 		// Find a good break point (word boundary)
-		if idx := strings.LastIndex(overlap, " "); idx > 0 {
+		if idx := strings.LastIndex(overlap, " "); idx >= 0 {
 			overlap = overlap[idx+1:]
 		}
 
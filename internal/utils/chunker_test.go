@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunker *Chunker
+		text    string
+		want    []string
+	}{
+		{
+			name:    "empty",
+			chunker: NewChunker(10, 0),
+			text:    "",
+			want:    nil,
+		},
+		{
+			name:    "fits in one chunk",
+			chunker: NewChunker(100, 0),
+			text:    "  short text  ",
+			want:    []string{"short text"},
+		},
+		{
+			name:    "paragraphs",
+			chunker: NewChunker(12, 0),
+			text:    "first para\n\nsecond para\n\nthird",
+			want:    []string{"first para", "second para", "third"},
+		},
+		{
+			name:    "paragraphs merged while they fit",
+			chunker: NewChunker(30, 0),
+			text:    "one\n\ntwo\n\nthree",
+			want:    []string{"one\n\ntwo\n\nthree"},
+		},
+		{
+			name:    "falls back to words",
+			chunker: NewChunker(11, 0),
+			text:    "alpha beta gamma delta",
+			want:    []string{"alpha beta", "gamma delta"},
+		},
+		{
+			name:    "no separators",
+			chunker: NewChunker(4, 0),
+			text:    "abcdefghij",
+			want:    []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:    "no separators with overlap",
+			chunker: NewChunker(4, 2),
+			text:    "abcdefgh",
+			want:    []string{"abcd", "cdef", "efgh"},
+		},
+		{
+			name:    "word overlap",
+			chunker: NewChunker(11, 5),
+			text:    "alpha beta gamma delta",
+			want:    []string{"alpha beta", "beta gamma delta"},
+		},
+		{
+			name:    "counts runes, not bytes",
+			chunker: NewChunker(3, 0),
+			text:    "日本語中文",
+			want:    []string{"日本語", "中文"},
+		},
+		{
+			name:    "token sizes",
+			chunker: NewTokenChunker(2, 0, TokenizerFunc(EstimateTokens)),
+			text:    "alpha beta gamma delta",
+			want:    []string{"alpha", "beta", "gamma", "delta"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.chunker.ChunkText(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChunkText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkTextRespectsSize(t *testing.T) {
+	text := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 40) +
+		strings.Repeat("x", 500) + "\n\n" + strings.Repeat("日本語のテキスト。", 60)
+
+	tests := []struct {
+		name      string
+		size      int
+		tokenizer Tokenizer
+	}{
+		{name: "characters", size: 200, tokenizer: Characters},
+		{name: "small characters", size: 16, tokenizer: Characters},
+		{name: "tokens", size: 50, tokenizer: TokenizerFunc(EstimateTokens)},
+		{name: "few tokens", size: 3, tokenizer: TokenizerFunc(EstimateTokens)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := NewTokenChunker(tt.size, 0, tt.tokenizer).ChunkText(text)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks, want the text split", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if n := tt.tokenizer.CountTokens(chunk); n > tt.size {
+					t.Errorf("chunk %d has size %d, over %d: %q", i, n, tt.size, chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestNewTokenChunkerDefaults(t *testing.T) {
+	tests := []struct {
+		name              string
+		size, overlap     int
+		wantSize, wantOvl int
+	}{
+		{name: "valid", size: 100, overlap: 10, wantSize: 100, wantOvl: 10},
+		{name: "default size", size: 0, overlap: 10, wantSize: 1000, wantOvl: 10},
+		{name: "negative overlap", size: 100, overlap: -1, wantSize: 100, wantOvl: 0},
+		{name: "overlap as large as size", size: 100, overlap: 100, wantSize: 100, wantOvl: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewTokenChunker(tt.size, tt.overlap, Characters)
+			if c.ChunkSize != tt.wantSize || c.ChunkOverlap != tt.wantOvl {
+				t.Errorf("size, overlap = %d, %d, want %d, %d", c.ChunkSize, c.ChunkOverlap, tt.wantSize, tt.wantOvl)
+			}
+		})
+	}
+}
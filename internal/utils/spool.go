@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const spoolFilePrefix = "upload-"
+
+// ErrFileTooLarge is returned when an upload exceeds the spool's size limit
+var ErrFileTooLarge = errors.New("file exceeds maximum allowed size")
+
+// FileSpool stores uploaded files on local disk until a worker processes them.
+// When the api and worker roles run as separate processes the directory must be
+// shared storage, which config.ValidateUploadSpool enforces.
+type FileSpool struct {
+	dir     string
+	maxSize int64
+}
+
+// NewFileSpool creates the spool directory if needed
+func NewFileSpool(dir string, maxSize int64) (*FileSpool, error) {
+	if maxSize <= 0 {
+		maxSize = MaxDownloadSize
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return &FileSpool{dir: dir, maxSize: maxSize}, nil
+}
+
+// MaxSize is the largest file the spool accepts
+func (s *FileSpool) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Write streams r into a new spool file and returns its name and size. Nothing
+// is left behind when it fails.
+func (s *FileSpool) Write(r io.Reader) (string, int64, error) {
+	file, err := os.CreateTemp(s.dir, spoolFilePrefix+"*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	name := filepath.Base(file.Name())
+
+	size, err := io.Copy(file, io.LimitReader(r, s.maxSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > s.maxSize {
+		err = fmt.Errorf("%w: %d bytes", ErrFileTooLarge, s.maxSize)
+	}
+	if err != nil {
+		s.Remove(name)
+		if errors.Is(err, ErrFileTooLarge) {
+			return "", 0, err
+		}
+		return "", 0, fmt.Errorf("failed to write spool file: %w", err)
+	}
+	return name, size, nil
+}
+
+// Read returns the content of a spool file
+func (s *FileSpool) Read(name string) ([]byte, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool file: %w", err)
+	}
+	return content, nil
+}
+
+// Remove deletes a spool file, ignoring files that are already gone
+func (s *FileSpool) Remove(name string) {
+	path, err := s.path(name)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		Zlog.Warn("Failed to remove spool file", zap.String("file", name), zap.Error(err))
+	}
+}
+
+// Sweep deletes spool files older than maxAge, left behind by jobs that were
+// cancelled or dropped before a worker picked them up. Files in inUse belong
+// to jobs still waiting in the queue and are kept however old they are.
+func (s *FileSpool) Sweep(maxAge time.Duration, inUse map[string]bool) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		Zlog.Warn("Failed to list spool directory", zap.String("dir", s.dir), zap.Error(err))
+		return
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), spoolFilePrefix) || inUse[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		s.Remove(entry.Name())
+		removed++
+	}
+	if removed > 0 {
+		Zlog.Info("Removed stale spool files", zap.Int("count", removed))
+	}
+}
+
+// path resolves name inside the spool directory so payloads cannot point elsewhere
+func (s *FileSpool) path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || !strings.HasPrefix(name, spoolFilePrefix) {
+		return "", fmt.Errorf("invalid spool file name %q", name)
+	}
+	return filepath.Join(s.dir, name), nil
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestSpool(t *testing.T, maxSize int64) *FileSpool {
+	t.Helper()
+	Zlog = zap.NewNop()
+	spool, err := NewFileSpool(t.TempDir(), maxSize)
+	if err != nil {
+		t.Fatalf("NewFileSpool() error = %v", err)
+	}
+	return spool
+}
+
+func TestFileSpoolPath(t *testing.T) {
+	spool := newTestSpool(t, 0)
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{name: "spool file", file: "upload-123"},
+		{name: "empty", file: "", wantErr: true},
+		{name: "missing prefix", file: "passwd", wantErr: true},
+		{name: "parent directory", file: "../upload-123", wantErr: true},
+		{name: "nested", file: "upload-dir/upload-123", wantErr: true},
+		{name: "absolute", file: "/etc/upload-123", wantErr: true},
+		{name: "dot dot", file: "..", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := spool.path(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("path(%q) error = %v, wantErr %v", tt.file, err, tt.wantErr)
+			}
+			if err == nil && filepath.Dir(path) != spool.dir {
+				t.Errorf("path(%q) = %q, outside %q", tt.file, path, spool.dir)
+			}
+		})
+	}
+}
+
+func TestFileSpoolWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{name: "small", content: "hello"},
+		{name: "exactly the limit", content: strings.Repeat("a", 16)},
+		{name: "over the limit", content: strings.Repeat("a", 17), wantErr: ErrFileTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spool := newTestSpool(t, 16)
+			name, size, err := spool.Write(strings.NewReader(tt.content))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Write() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if entries, _ := os.ReadDir(spool.dir); len(entries) != 0 {
+					t.Errorf("failed write left %d files behind", len(entries))
+				}
+				return
+			}
+
+			if size != int64(len(tt.content)) {
+				t.Errorf("size = %d, want %d", size, len(tt.content))
+			}
+			content, err := spool.Read(name)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if string(content) != tt.content {
+				t.Errorf("Read() = %q, want %q", content, tt.content)
+			}
+
+			spool.Remove(name)
+			if _, err := spool.Read(name); err == nil {
+				t.Error("Read() after Remove() succeeded")
+			}
+		})
+	}
+}
+
+func TestFileSpoolSweep(t *testing.T) {
+	spool := newTestSpool(t, 0)
+	write := func(age time.Duration) string {
+		t.Helper()
+		name, _, err := spool.Write(strings.NewReader("data"))
+		if err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(filepath.Join(spool.dir, name), modTime, modTime); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+		return name
+	}
+
+	fresh := write(time.Minute)
+	stale := write(2 * time.Hour)
+	queued := write(2 * time.Hour)
+	other := filepath.Join(spool.dir, "unrelated")
+	if err := os.WriteFile(other, nil, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(other, old, old); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	spool.Sweep(time.Hour, map[string]bool{queued: true})
+
+	tests := []struct {
+		name string
+		file string
+		kept bool
+	}{
+		{name: "fresh", file: fresh, kept: true},
+		{name: "stale", file: stale, kept: false},
+		{name: "stale but queued", file: queued, kept: true},
+		{name: "not a spool file", file: "unrelated", kept: true},
+	}
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(spool.dir, tt.file))
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("%s: kept = %v, want %v", tt.name, kept, tt.kept)
+		}
+	}
+}
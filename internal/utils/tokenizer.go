@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer measures text for chunking and for embedding input limits
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc adapts a counting function to Tokenizer
+type TokenizerFunc func(text string) int
+
+// CountTokens calls f
+func (f TokenizerFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// Characters measures text in runes, the unit chunk sizes default to
+var Characters Tokenizer = TokenizerFunc(utf8.RuneCountInString)
+
+// DefaultTokenizer measures token-sized chunks and chunks against embedding
+// input limits. Replace it at startup to count with a model's own vocabulary.
+var DefaultTokenizer Tokenizer = TokenizerFunc(EstimateTokens)
+
+// EstimateTokens approximates subword tokenizers without a vocabulary and
+// errs high: a token per four ASCII letters, three digits or two letters of
+// other alphabets, and one per CJK character or symbol. Whitespace is free.
+func EstimateTokens(text string) int {
+	tokens := 0
+	var ascii, other, digits int
+	endWord := func() {
+		tokens += (ascii+3)/4 + (other+1)/2 + (digits+2)/3
+		ascii, other, digits = 0, 0, 0
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			endWord()
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			ascii++
+		case unicode.IsDigit(r):
+			digits++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			endWord()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			other++
+		default:
+			endWord()
+			tokens++
+		}
+	}
+	endWord()
+	return tokens
+}
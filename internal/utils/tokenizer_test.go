@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "empty", text: "", want: 0},
+		{name: "whitespace", text: " \n\t ", want: 0},
+		{name: "short word", text: "cat", want: 1},
+		{name: "ascii words", text: "hello world", want: 4},
+		{name: "punctuation", text: "hello, world!", want: 6},
+		{name: "digits", text: "1234567", want: 3},
+		{name: "mixed word", text: "abc123", want: 2},
+		{name: "accented", text: "héllo", want: 2},
+		{name: "cyrillic", text: "привет", want: 3},
+		{name: "cjk", text: "日本語", want: 3},
+		{name: "cjk with latin", text: "東京tokyo", want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateTokens(tt.text); got != tt.want {
+				t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCharacters(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "hello", want: 5},
+		{text: "héllo", want: 5},
+		{text: "日本語", want: 3},
+	}
+
+	for _, tt := range tests {
+		if got := Characters.CountTokens(tt.text); got != tt.want {
+			t.Errorf("Characters.CountTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
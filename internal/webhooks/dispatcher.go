@@ -0,0 +1,335 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Conversly/db-ingestor/internal/loaders"
+	"github.com/Conversly/db-ingestor/internal/types"
+	"github.com/Conversly/db-ingestor/internal/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// A claimed batch is sent concurrently, so it takes one deliveryTimeout and
+// the lease only has to outlast a single send and the recording of its result
+const (
+	deliveryTimeout = 15 * time.Second
+	deliveryLease   = time.Minute
+	claimBatchSize  = 20
+	baseBackoff     = 10 * time.Second
+	maxBackoff      = time.Hour
+)
+
+// Dispatcher turns job and datasource outcomes into durable webhook deliveries
+// and sends them with retries. Deliveries live in Postgres, so any replica
+// running the dispatcher can send them and they survive restarts.
+type Dispatcher struct {
+	db           *loaders.PostgresClient
+	client       *http.Client
+	secret       []byte
+	maxAttempts  int
+	pollInterval time.Duration
+	quit         chan struct{}
+	wakeup       chan struct{}
+	wg           sync.WaitGroup
+	started      bool
+}
+
+// NewDispatcher creates a dispatcher signing with secret. Callbacks are
+// disabled when secret is empty.
+func NewDispatcher(db *loaders.PostgresClient, secret string, maxAttempts int, pollInterval time.Duration) *Dispatcher {
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+	return &Dispatcher{
+		db:           db,
+		client:       &http.Client{Timeout: deliveryTimeout},
+		secret:       []byte(secret),
+		maxAttempts:  maxAttempts,
+		pollInterval: pollInterval,
+		quit:         make(chan struct{}),
+		wakeup:       make(chan struct{}, 1),
+	}
+}
+
+// Enabled reports whether callback URLs can be accepted
+func (d *Dispatcher) Enabled() bool {
+	return d != nil && len(d.secret) > 0
+}
+
+// NotifyDatasource queues a datasource.completed or datasource.failed event if
+// the datasource reached a final state and its job has a callback URL
+func (d *Dispatcher) NotifyDatasource(ctx context.Context, jobID, datasourceID string) {
+	if !d.Enabled() {
+		return
+	}
+	job, ok := d.loadJob(ctx, jobID)
+	if !ok {
+		return
+	}
+
+	for i := range job.Results {
+		result := job.Results[i]
+		if result.DatasourceID != datasourceID {
+			continue
+		}
+
+		var eventType string
+		switch result.Status {
+		case types.SourceStatusCompleted:
+			eventType = types.WebhookEventDatasourceCompleted
+		case types.SourceStatusFailed:
+			eventType = types.WebhookEventDatasourceFailed
+		default:
+			return
+		}
+
+		d.enqueue(ctx, job, types.WebhookEvent{
+			Type:         eventType,
+			DatasourceID: datasourceID,
+			Status:       result.Status,
+			Source:       &result,
+		})
+		return
+	}
+}
+
+// NotifyJob queues a job.finished event carrying the final job state
+func (d *Dispatcher) NotifyJob(ctx context.Context, jobID string) {
+	if !d.Enabled() {
+		return
+	}
+	job, ok := d.loadJob(ctx, jobID)
+	if !ok {
+		return
+	}
+
+	d.enqueue(ctx, job, types.WebhookEvent{
+		Type:   types.WebhookEventJobFinished,
+		Status: string(job.Status),
+		Job:    job,
+	})
+}
+
+// Replay schedules an existing delivery to be sent again
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID string) error {
+	if err := d.db.ReplayWebhookDelivery(ctx, deliveryID); err != nil {
+		return err
+	}
+	d.nudge()
+	return nil
+}
+
+func (d *Dispatcher) loadJob(ctx context.Context, jobID string) (*types.IngestionRecord, bool) {
+	job, err := d.db.GetIngestionJob(ctx, jobID)
+	if err != nil {
+		utils.Zlog.Error("Failed to load job for webhook",
+			zap.String("jobId", jobID),
+			zap.Error(err))
+		return nil, false
+	}
+	return job, job.CallbackURL != ""
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, job *types.IngestionRecord, event types.WebhookEvent) {
+	event.ID = uuid.New().String()
+	event.JobID = job.ID
+	event.UserID = job.UserID
+	event.ChatbotID = job.ChatbotID
+	event.OccurredAt = time.Now().UTC()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		utils.Zlog.Error("Failed to encode webhook event",
+			zap.String("jobId", job.ID),
+			zap.String("event", event.Type),
+			zap.Error(err))
+		return
+	}
+
+	delivery := types.WebhookDelivery{
+		ID:        event.ID,
+		JobID:     job.ID,
+		EventType: event.Type,
+		URL:       job.CallbackURL,
+		Payload:   payload,
+	}
+	if err := d.db.CreateWebhookDelivery(ctx, delivery); err != nil {
+		utils.Zlog.Error("Failed to store webhook delivery",
+			zap.String("jobId", job.ID),
+			zap.String("event", event.Type),
+			zap.Error(err))
+		return
+	}
+
+	utils.Zlog.Info("Webhook event queued",
+		zap.String("jobId", job.ID),
+		zap.String("deliveryId", delivery.ID),
+		zap.String("event", event.Type))
+	d.nudge()
+}
+
+func (d *Dispatcher) nudge() {
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Start begins sending due deliveries in the background
+func (d *Dispatcher) Start() {
+	if d.started || !d.Enabled() {
+		return
+	}
+	d.started = true
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		utils.Zlog.Info("Webhook dispatcher started")
+		for {
+			d.deliverDue()
+			select {
+			case <-d.quit:
+				utils.Zlog.Info("Webhook dispatcher stopping")
+				return
+			case <-d.wakeup:
+			case <-time.After(d.pollInterval):
+			}
+		}
+	}()
+}
+
+// Stop waits for the current batch of deliveries to finish
+func (d *Dispatcher) Stop(ctx context.Context) {
+	if !d.started {
+		return
+	}
+	close(d.quit)
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		utils.Zlog.Warn("Timeout waiting for webhook dispatcher to stop")
+	case <-done:
+	}
+}
+
+func (d *Dispatcher) deliverDue() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	deliveries, err := d.db.ClaimDueWebhookDeliveries(ctx, claimBatchSize, deliveryLease)
+	cancel()
+	if err != nil {
+		utils.Zlog.Error("Failed to claim webhook deliveries", zap.Error(err))
+		return
+	}
+
+	// Sending one at a time would let slow endpoints outlast the lease and
+	// another replica re-send the rest of the batch
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) deliver(delivery types.WebhookDelivery) {
+	start := time.Now()
+	statusCode, err := d.send(delivery)
+	attempt := types.WebhookAttempt{
+		Attempt:    delivery.Attempts,
+		StatusCode: statusCode,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	status := types.WebhookStatusDelivered
+	nextAttemptAt := time.Now().UTC()
+	if err != nil {
+		attempt.Error = err.Error()
+		if delivery.Attempts >= d.maxAttempts {
+			status = types.WebhookStatusFailed
+		} else {
+			status = types.WebhookStatusPending
+			nextAttemptAt = nextAttemptAt.Add(backoff(delivery.Attempts))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.db.RecordWebhookAttempt(ctx, delivery.ID, attempt, status, nextAttemptAt); err != nil {
+		utils.Zlog.Error("Failed to record webhook attempt",
+			zap.String("deliveryId", delivery.ID),
+			zap.Error(err))
+	}
+
+	if err != nil {
+		utils.Zlog.Warn("Webhook delivery attempt failed",
+			zap.String("deliveryId", delivery.ID),
+			zap.String("jobId", delivery.JobID),
+			zap.Int("attempt", delivery.Attempts),
+			zap.String("status", status),
+			zap.Error(err))
+		return
+	}
+	utils.Zlog.Info("Webhook delivered",
+		zap.String("deliveryId", delivery.ID),
+		zap.String("jobId", delivery.JobID),
+		zap.String("event", delivery.EventType),
+		zap.Int("attempt", delivery.Attempts))
+}
+
+func (d *Dispatcher) send(delivery types.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDeliveryID, delivery.ID)
+	req.Header.Set(HeaderSignature, Sign(d.secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt following attempt, doubling from
+// baseBackoff up to maxBackoff with up to 50% jitter
+func backoff(attempt int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+}
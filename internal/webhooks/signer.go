@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Headers set on every webhook request
+const (
+	HeaderSignature  = "X-Ingestor-Signature"
+	HeaderEvent      = "X-Ingestor-Event"
+	HeaderDeliveryID = "X-Ingestor-Delivery"
+)
+
+// Sign returns the signature header value for body sent at timestamp.
+// Receivers recompute HMAC-SHA256(secret, "<t>.<body>") and compare it with v1,
+// rejecting timestamps that are too old to prevent replays.
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", t, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"job.completed"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp time.Time
+		body      []byte
+		want      string
+	}{
+		{
+			name:      "payload",
+			secret:    "secret",
+			timestamp: timestamp,
+			body:      body,
+			want:      "t=1700000000,v1=e33f34cc0b46f4e752fe75a10d7177366fd795c052ed09dfa63608265c13be69",
+		},
+		{
+			name:      "other secret",
+			secret:    "other",
+			timestamp: timestamp,
+			body:      body,
+			want:      "t=1700000000,v1=f292f537f3361ea36923d5397f4b7a2a068dc85301bd37a95eef86df12e034f2",
+		},
+		{
+			name:      "empty body",
+			secret:    "secret",
+			timestamp: timestamp,
+			want:      "t=1700000000,v1=4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5",
+		},
+		{
+			name:      "sub-second timestamp",
+			secret:    "secret",
+			timestamp: timestamp.Add(999 * time.Millisecond),
+			body:      body,
+			want:      "t=1700000000,v1=e33f34cc0b46f4e752fe75a10d7177366fd795c052ed09dfa63608265c13be69",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign([]byte(tt.secret), tt.timestamp, tt.body); got != tt.want {
+				t.Errorf("Sign() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -67,7 +67,7 @@ func main() {
 	// Initialize Gemini embedder
 	logger.Info("Initializing Gemini embedder")
 	keys := parseAPIKeys(*apiKeys)
-	geminiEmbedder, err := embedder.NewGeminiEmbedder(keys)
+	geminiEmbedder, err := embedder.NewGeminiEmbedder(keys, embedder.Settings{}, embedder.RateLimits{})
 	if err != nil {
 		logger.Fatal("Failed to initialize Gemini embedder", zap.Error(err))
 	}
@@ -157,12 +157,12 @@ func parseAPIKeys(keysStr string) []string {
 func processBatch(
 	ctx context.Context,
 	batch []JSONRecord,
-	geminiEmbedder *embedder.GeminiEmbedder,
+	textEmbedder embedder.Embedder,
 	pgClient *loaders.PostgresClient,
 	logger *zap.Logger,
 ) (processed, failed int) {
 	for _, record := range batch {
-		if err := processRecord(ctx, record, geminiEmbedder, pgClient, logger); err != nil {
+		if err := processRecord(ctx, record, textEmbedder, pgClient, logger); err != nil {
 			logger.Error("Failed to process record",
 				zap.Int("id", record.ID),
 				zap.Error(err))
@@ -179,7 +179,7 @@ func processBatch(
 func processRecord(
 	ctx context.Context,
 	record JSONRecord,
-	geminiEmbedder *embedder.GeminiEmbedder,
+	textEmbedder embedder.Embedder,
 	pgClient *loaders.PostgresClient,
 	logger *zap.Logger,
 ) error {
@@ -210,7 +210,7 @@ func processRecord(
 	embedCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	embedding, err := geminiEmbedder.EmbedText(embedCtx, record.Text)
+	embedding, err := textEmbedder.EmbedText(embedCtx, record.Text)
 	if err != nil {
 		return fmt.Errorf("failed to generate embedding: %w", err)
 	}
@@ -226,6 +226,7 @@ func processRecord(
 			Vector:       embedding,
 			DataSourceID: &dataSourceID,
 			Citation:     record.Citation,
+			Model:        textEmbedder.ModelID(),
 		},
 	}
 
@@ -233,9 +234,13 @@ func processRecord(
 	insertCtx, insertCancel := context.WithTimeout(ctx, 10*time.Second)
 	defer insertCancel()
 
-	if err := pgClient.BatchInsertEmbeddings(insertCtx, userID, chatbotID, embeddingData); err != nil {
+	result, err := pgClient.BatchInsertEmbeddings(insertCtx, userID, chatbotID, embeddingData)
+	if err != nil {
 		return fmt.Errorf("failed to insert embedding: %w", err)
 	}
+	if len(result.Rejected) > 0 {
+		return fmt.Errorf("embedding rejected by the database: %s", result.Rejected[0].Reason)
+	}
 
 	logger.Info("Successfully saved embedding to database",
 		zap.Int("id", record.ID),